@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Key of the ConfigMap entry holding the usage history
+const forecastConfigMapKey = "history.json"
+
+// The history is thinned out above this size, ConfigMaps are limited to 1 MiB
+const forecastConfigMapMaxBytes = 900 * 1024
+
+// A trend needs to cover at least this much time before it's trusted
+const forecastMinSpan = time.Hour
+
+// Projections further out than this are treated as never filling up, and stay well within time.Duration
+const forecastMaxTimeToFull = 100 * 365 * 24 * time.Hour
+
+// forecastSample is a single usage measurement, stored as [unix seconds, used bytes, capacity bytes]
+type forecastSample [3]int64
+
+func (s forecastSample) time() time.Time { return time.Unix(s[0], 0) }
+func (s forecastSample) used() int64     { return s[1] }
+func (s forecastSample) capacity() int64 { return s[2] }
+
+// forecastSeries is the usage history of a single volume or disk
+type forecastSeries struct {
+	Kind    string           `json:"kind"`
+	Name    string           `json:"name"`
+	Samples []forecastSample `json:"samples"`
+}
+
+// forecastResult is the trend fitted through a series
+type forecastResult struct {
+	growthPerDay float64 // bytes per day
+	fillsUp      bool    // timeToFull and fullAt are only set when the trend fills up within forecastMaxTimeToFull
+	timeToFull   time.Duration
+	fullAt       time.Time
+	used         int64
+	capacity     int64
+}
+
+var (
+	forecastHistory     = make(map[string]*forecastSeries)
+	forecastHistoryLock sync.RWMutex
+
+	forecastStates     = make(map[string]unitState)
+	forecastStatesLock sync.RWMutex
+)
+
+func markForecastAlertSent(key string) {
+	forecastStatesLock.Lock()
+	defer forecastStatesLock.Unlock()
+
+	if state, exists := forecastStates[key]; exists {
+		state.alertSent = true
+		forecastStates[key] = state
+	}
+}
+
+// setupCapacityForecasting loads persisted history and starts sampling usage
+func setupCapacityForecasting(ctx context.Context) {
+	if !config.Forecasting.Enabled {
+		log.Info().Msg("Capacity forecasting is disabled")
+		return
+	}
+
+	interval := time.Duration(config.Forecasting.SampleIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+
+	if err := loadForecastHistory(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to load capacity forecast history, starting empty")
+	}
+
+	log.Info().
+		Dur("interval", interval).
+		Int("horizon_days", config.Forecasting.HorizonDays).
+		Msg("Setting up capacity forecasting")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				recordForecastSamples()
+				checkCapacityForecasts()
+				saveForecastHistory(ctx)
+			}
+		}
+	}()
+}
+
+// recordForecastSamples appends the current usage of every PVC, Longhorn volume and Longhorn disk to its history
+func recordForecastSamples() {
+	now := time.Now()
+
+	// PVC filesystem usage from kubelet stats is the most accurate source
+	pvcUsagesLock.RLock()
+	for key, usage := range pvcUsages {
+		addForecastSample("pvc:"+key, "PVC", key, now, usage.usedBytes, usage.capacityBytes)
+	}
+	pvcUsagesLock.RUnlock()
+
+	// Longhorn volumes fall back to actualSize when their PVC isn't covered by kubelet stats
+	if volumeInformer, ok := getLonghornInformer("volumes"); ok {
+		for _, obj := range volumeInformer.GetStore().List() {
+			volume, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			if _, hasPVCUsage := getPVCUsage(getLonghornKubernetesStatus(volume).pvcRef()); hasPVCUsage {
+				continue
+			}
+
+			sizeStr, _, _ := unstructured.NestedString(volume.Object, "spec", "size")
+			actualSize, _, _ := unstructured.NestedInt64(volume.Object, "status", "actualSize")
+			key := fmt.Sprintf("%s/%s", volume.GetNamespace(), volume.GetName())
+			addForecastSample("volume:"+key, "Longhorn Volume", key, now, actualSize, parseSize(sizeStr))
+		}
+	}
+
+	// Disk usage is derived from storageAvailable when disk states are recorded
+	longhornDiskStatesLock.RLock()
+	for key, state := range longhornDiskStates {
+		addForecastSample("disk:"+key, "Longhorn Disk", key, now, state.usage, state.capacity)
+	}
+	longhornDiskStatesLock.RUnlock()
+
+	pruneForecastHistory(now)
+}
+
+// addForecastSample appends a sample to a series, creating it if needed
+func addForecastSample(key, kind, name string, now time.Time, used, capacity int64) {
+	if capacity <= 0 {
+		return
+	}
+
+	forecastHistoryLock.Lock()
+	defer forecastHistoryLock.Unlock()
+
+	series, exists := forecastHistory[key]
+	if !exists {
+		series = &forecastSeries{Kind: kind, Name: name}
+		forecastHistory[key] = series
+	}
+	series.Samples = append(series.Samples, forecastSample{now.Unix(), used, capacity})
+}
+
+// pruneForecastHistory drops samples outside the history window and series that stopped reporting
+func pruneForecastHistory(now time.Time) {
+	window := time.Duration(config.Forecasting.HistoryHours) * time.Hour
+	cutoff := now.Add(-window).Unix()
+
+	forecastHistoryLock.Lock()
+	defer forecastHistoryLock.Unlock()
+
+	for key, series := range forecastHistory {
+		first := sort.Search(len(series.Samples), func(i int) bool {
+			return series.Samples[i][0] >= cutoff
+		})
+		series.Samples = series.Samples[first:]
+		if len(series.Samples) == 0 {
+			delete(forecastHistory, key)
+		}
+	}
+}
+
+// fitForecast fits a least-squares trend line through a series and projects when it will be full
+func fitForecast(samples []forecastSample) (forecastResult, bool) {
+	if len(samples) < config.Forecasting.MinSamples || len(samples) < 2 {
+		return forecastResult{}, false
+	}
+
+	first := samples[0].time()
+	last := samples[len(samples)-1]
+	if last.time().Sub(first) < forecastMinSpan {
+		return forecastResult{}, false
+	}
+
+	// Regress used bytes over seconds since the first sample
+	var sumX, sumY, sumXY, sumXX float64
+	n := float64(len(samples))
+	for _, sample := range samples {
+		x := sample.time().Sub(first).Seconds()
+		y := float64(sample.used())
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return forecastResult{}, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+
+	result := forecastResult{
+		growthPerDay: slope * 24 * 60 * 60,
+		used:         last.used(),
+		capacity:     last.capacity(),
+	}
+
+	// Shrinking or flat usage never fills up
+	if slope <= 0 {
+		return result, true
+	}
+
+	remaining := float64(last.capacity() - last.used())
+	if remaining < 0 {
+		remaining = 0
+	}
+	// Keep the projection in float seconds until it's known to fit, slow growth overflows a Duration
+	secondsToFull := remaining / slope
+	if secondsToFull >= forecastMaxTimeToFull.Seconds() {
+		return result, true
+	}
+
+	result.fillsUp = true
+	result.timeToFull = time.Duration(secondsToFull * float64(time.Second))
+	result.fullAt = last.time().Add(result.timeToFull)
+
+	return result, true
+}
+
+// checkCapacityForecasts alerts on every series projected to fill up within the horizon
+func checkCapacityForecasts() {
+	horizon := time.Duration(config.Forecasting.HorizonDays) * 24 * time.Hour
+
+	forecastHistoryLock.RLock()
+	series := make(map[string]forecastSeries, len(forecastHistory))
+	for key, s := range forecastHistory {
+		series[key] = forecastSeries{Kind: s.Kind, Name: s.Name, Samples: append([]forecastSample(nil), s.Samples...)}
+	}
+	forecastHistoryLock.RUnlock()
+
+	for key, s := range series {
+		result, ok := fitForecast(s.Samples)
+		if !ok {
+			continue
+		}
+
+		hasError := result.fillsUp && result.timeToFull < horizon
+		var errorMessage string
+		if hasError {
+			errorMessage = fmt.Sprintf("Projected to be full in %s", formatForecastDuration(result.timeToFull))
+		}
+
+		log.Debug().
+			Str("resource", key).
+			Float64("growthPerDay", result.growthPerDay).
+			Dur("timeToFull", result.timeToFull).
+			Msg("Processing capacity forecast")
+
+		// Check for recovery before the state is reset
+		if !hasError {
+			forecastStatesLock.RLock()
+			prevState, exists := forecastStates[key]
+			forecastStatesLock.RUnlock()
+
+			if exists && prevState.hasError && prevState.alertSent {
+				sendCapacityForecastRecoveryAlert(key, s, result)
+			}
+		}
+
+		updateForecastState(key, hasError, errorMessage)
+
+		if hasError && shouldSendAlert("forecast", key) {
+			sendCapacityForecastAlert(key, s, result, horizon)
+			markForecastAlertSent(key)
+		}
+	}
+
+	// Forget states of series that were pruned
+	forecastStatesLock.Lock()
+	for key := range forecastStates {
+		if _, exists := series[key]; !exists {
+			delete(forecastStates, key)
+		}
+	}
+	forecastStatesLock.Unlock()
+}
+
+// sendCapacityForecastAlert sends an alert for a volume or disk projected to fill up within the horizon
+func sendCapacityForecastAlert(key string, series forecastSeries, result forecastResult, horizon time.Duration) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Capacity Forecast Alert for %s", series.Kind),
+		Description: fmt.Sprintf("%s %s is projected to be full in %s", series.Kind, series.Name, formatForecastDuration(result.timeToFull)),
+		Fields:      forecastFields(series, result),
+	}
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "Horizon", Value: formatForecastDuration(horizon), Inline: true})
+
+	if !routeLonghornAlert(&alert, forecastKubernetesStatus(key)) {
+		return
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("kind", series.Kind).
+		Str("name", series.Name).
+		Dur("timeToFull", result.timeToFull).
+		Msg("Capacity forecast alert sent")
+}
+
+// sendCapacityForecastRecoveryAlert sends a recovery alert once the projection is beyond the horizon again
+func sendCapacityForecastRecoveryAlert(key string, series forecastSeries, result forecastResult) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Capacity Forecast Recovery for %s", series.Kind),
+		Description: fmt.Sprintf("%s %s is no longer projected to be full within the horizon", series.Kind, series.Name),
+		Fields:      forecastFields(series, result),
+	}
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "State", Value: "Healthy", Inline: true})
+
+	if !routeLonghornAlert(&alert, forecastKubernetesStatus(key)) {
+		return
+	}
+
+	sendWebhookMessage(alert)
+	log.Info().
+		Str("kind", series.Kind).
+		Str("name", series.Name).
+		Msg("Capacity forecast has recovered")
+}
+
+// forecastKubernetesStatus returns the PVC behind a series for the storage alert namespace filters and routes,
+// or nil for disks
+func forecastKubernetesStatus(key string) *longhornKubernetesStatus {
+	kind, name, _ := strings.Cut(key, ":")
+	switch kind {
+	case "pvc":
+		namespace, pvcName, _ := strings.Cut(name, "/")
+		return &longhornKubernetesStatus{pvcName: pvcName, pvcNamespace: namespace}
+	case "volume":
+		volumeInformer, ok := getLonghornInformer("volumes")
+		if !ok {
+			return nil
+		}
+		obj, exists, err := volumeInformer.GetStore().GetByKey(name)
+		if err != nil || !exists {
+			return nil
+		}
+		if volume, ok := obj.(*unstructured.Unstructured); ok {
+			return getLonghornKubernetesStatus(volume)
+		}
+	}
+	return nil
+}
+
+// forecastFields returns alert fields describing a forecast
+func forecastFields(series forecastSeries, result forecastResult) []struct {
+	Name   string
+	Value  string
+	Inline bool
+} {
+	fields := []struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{
+		{Name: "Resource", Value: series.Name, Inline: true},
+		{Name: "Type", Value: series.Kind, Inline: true},
+		{Name: "Used", Value: fmt.Sprintf("%s of %s", formatLonghornBytes(result.used), formatLonghornBytes(result.capacity)), Inline: true},
+		{Name: "Growth Rate", Value: fmt.Sprintf("%.2f GB/day", result.growthPerDay/(1024*1024*1024)), Inline: true},
+		{Name: "Samples", Value: fmt.Sprintf("%d", len(series.Samples)), Inline: true},
+	}
+
+	if result.fillsUp {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Time to Full", Value: formatForecastDuration(result.timeToFull), Inline: true}, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Estimated Full Date", Value: result.fullAt.UTC().Format("2006-01-02 15:04 MST"), Inline: true})
+	}
+
+	return fields
+}
+
+// formatForecastDuration renders a duration in days and hours
+func formatForecastDuration(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
+
+func updateForecastState(key string, hasError bool, errorMessage string) {
+	forecastStatesLock.Lock()
+	defer forecastStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := forecastStates[key]
+
+	newState := unitState{
+		hasError:    hasError,
+		lastSeen:    now,
+		lastMessage: errorMessage,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	forecastStates[key] = newState
+}
+
+// loadForecastHistory restores the usage history from the ConfigMap
+func loadForecastHistory(ctx context.Context) error {
+	namespace := detectNamespace()
+	configMap, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, config.Forecasting.ConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s/%s: %v", namespace, config.Forecasting.ConfigMapName, err)
+	}
+
+	data, exists := configMap.Data[forecastConfigMapKey]
+	if !exists {
+		return nil
+	}
+
+	history := make(map[string]*forecastSeries)
+	if err := json.Unmarshal([]byte(data), &history); err != nil {
+		return fmt.Errorf("failed to parse forecast history: %v", err)
+	}
+
+	forecastHistoryLock.Lock()
+	forecastHistory = history
+	forecastHistoryLock.Unlock()
+
+	log.Info().Int("series", len(history)).Msg("Loaded capacity forecast history")
+	return nil
+}
+
+// saveForecastHistory persists the usage history to the ConfigMap, only the leader writes it
+func saveForecastHistory(ctx context.Context) {
+	leaderLock.RLock()
+	leader := isLeader
+	leaderLock.RUnlock()
+	if !leader {
+		return
+	}
+
+	data, err := encodeForecastHistory()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode capacity forecast history")
+		return
+	}
+
+	namespace := detectNamespace()
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+
+	configMap, err := configMaps.Get(ctx, config.Forecasting.ConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      config.Forecasting.ConfigMapName,
+				Namespace: namespace,
+			},
+			Data: map[string]string{forecastConfigMapKey: string(data)},
+		}
+		if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+			log.Error().Err(err).Msg("Failed to create capacity forecast ConfigMap")
+		}
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get capacity forecast ConfigMap")
+		return
+	}
+
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[forecastConfigMapKey] = string(data)
+	if _, err := configMaps.Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		log.Error().Err(err).Msg("Failed to update capacity forecast ConfigMap")
+	}
+}
+
+// encodeForecastHistory serializes the usage history, halving the samples of every series until it fits the ConfigMap.
+// Thinning keeps the first and last samples, so the trends still cover the same time span.
+func encodeForecastHistory() ([]byte, error) {
+	forecastHistoryLock.Lock()
+	defer forecastHistoryLock.Unlock()
+
+	for {
+		data, err := json.Marshal(forecastHistory)
+		if err != nil || len(data) <= forecastConfigMapMaxBytes {
+			return data, err
+		}
+
+		thinned := false
+		for _, series := range forecastHistory {
+			if len(series.Samples) <= 2 {
+				continue
+			}
+			samples := make([]forecastSample, 0, len(series.Samples)/2+2)
+			for i := 0; i < len(series.Samples)-1; i += 2 {
+				samples = append(samples, series.Samples[i])
+			}
+			series.Samples = append(samples, series.Samples[len(series.Samples)-1])
+			thinned = true
+		}
+		if !thinned {
+			return nil, fmt.Errorf("forecast history of %d series exceeds %d bytes", len(forecastHistory), forecastConfigMapMaxBytes)
+		}
+
+		log.Warn().
+			Int("size", len(data)).
+			Int("max_size", forecastConfigMapMaxBytes).
+			Msg("Capacity forecast history is too large, thinning out samples")
+	}
+}
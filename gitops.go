@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -57,7 +60,8 @@ func setupGitOpsMonitoring(ctx context.Context) error {
 			branch = "main"
 		}
 
-		localPath := filepath.Join(tempDir, repo.Name)
+		// Repository names are free text, keep the clone directly in the temp directory
+		localPath := filepath.Join(tempDir, gitOpsConfigMapKey(repo.Name))
 
 		// Determine sync interval (repository-specific or global default)
 		syncIntervalMinutes := repo.SyncIntervalMinutes
@@ -76,6 +80,10 @@ func setupGitOpsMonitoring(ctx context.Context) error {
 			branch:       branch,
 			localPath:    localPath,
 			syncInterval: syncInterval,
+			autoFix:      repo.AutoFix,
+			auth:         repo.Auth,
+			webhook:      repo.Webhook,
+			syncRequests: make(chan struct{}, 1),
 		}
 
 		log.Debug().
@@ -89,11 +97,21 @@ func setupGitOpsMonitoring(ctx context.Context) error {
 	}
 	gitOpsRepositoriesLock.Unlock()
 
+	if config.GitOps.DetectExtraResources {
+		if err := loadGitOpsInventory(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to load GitOps inventory, resources removed from Git before this start won't be detected")
+		}
+	}
+
 	// Start monitoring goroutines for each repository
 	for _, repoState := range gitOpsRepositories {
 		go monitorGitOpsRepository(ctx, repoState)
 	}
 
+	if config.GitOps.Webhook.Enabled {
+		go startGitOpsWebhookServer(ctx)
+	}
+
 	log.Info().Msg("GitOps monitoring started")
 	return nil
 }
@@ -102,9 +120,9 @@ func setupGitOpsMonitoring(ctx context.Context) error {
 func monitorGitOpsRepository(ctx context.Context, repoState *gitOpsRepositoryState) {
 	log.Info().Str("repository", repoState.name).Msg("Starting GitOps repository monitoring")
 
-	// Initial sync
-	if err := syncRepository(repoState); err != nil {
-		log.Error().Err(err).Str("repository", repoState.name).Msg("Failed initial repository sync")
+	// Initial sync, retried until the repository is reachable
+	if !syncRepositoryWithRetry(ctx, repoState) {
+		log.Info().Str("repository", repoState.name).Msg("Stopping GitOps repository monitoring")
 		return
 	}
 
@@ -123,25 +141,36 @@ func monitorGitOpsRepository(ctx context.Context, repoState *gitOpsRepositorySta
 			log.Info().Str("repository", repoState.name).Msg("Stopping GitOps repository monitoring")
 			return
 		case <-ticker.C:
-			// Check if we're the leader before doing work
-			leaderLock.RLock()
-			if !isLeader {
-				leaderLock.RUnlock()
-				continue
-			}
-			leaderLock.RUnlock()
+			syncAndCompareRepository(repoState)
+		case <-repoState.syncRequests:
+			syncAndCompareRepository(repoState)
+			// The push already brought the repository up to date, restart the polling interval
+			ticker.Reset(repoState.syncInterval)
+		}
+	}
+}
 
-			log.Debug().Str("repository", repoState.name).Msg("Syncing GitOps repository")
+// syncAndCompareRepository pulls a repository and compares it with the cluster, only on the leader
+func syncAndCompareRepository(repoState *gitOpsRepositoryState) {
+	// Check if we're the leader before doing work
+	leaderLock.RLock()
+	if !isLeader {
+		leaderLock.RUnlock()
+		return
+	}
+	leaderLock.RUnlock()
 
-			if err := syncRepository(repoState); err != nil {
-				log.Error().Err(err).Str("repository", repoState.name).Msg("Failed to sync repository")
-				continue
-			}
+	log.Debug().Str("repository", repoState.name).Msg("Syncing GitOps repository")
 
-			if err := compareManifests(repoState); err != nil {
-				log.Error().Err(err).Str("repository", repoState.name).Msg("Failed to compare manifests")
-			}
-		}
+	err := syncRepository(repoState)
+	recordGitOpsSyncResult(repoState, err)
+	if err != nil {
+		log.Error().Err(err).Str("repository", repoState.name).Msg("Failed to sync repository")
+		return
+	}
+
+	if err := compareManifests(repoState); err != nil {
+		log.Error().Err(err).Str("repository", repoState.name).Msg("Failed to compare manifests")
 	}
 }
 
@@ -152,6 +181,11 @@ func syncRepository(repoState *gitOpsRepositoryState) error {
 
 	log.Debug().Str("repository", repoState.name).Str("url", repoState.url).Msg("Syncing repository")
 
+	auth, err := getGitOpsAuth(repoState)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials for repository %s: %w", repoState.name, err)
+	}
+
 	// Check if repository already exists locally
 	if repoState.repository == nil {
 		// Clone repository
@@ -159,11 +193,14 @@ func syncRepository(repoState *gitOpsRepositoryState) error {
 
 		repo, err := git.PlainClone(repoState.localPath, false, &git.CloneOptions{
 			URL:           repoState.url,
+			Auth:          auth,
 			ReferenceName: plumbing.ReferenceName(fmt.Sprintf("refs/heads/%s", repoState.branch)),
 			SingleBranch:  true,
-			Depth:         1, // Shallow clone for efficiency
+			Depth:         cloneDepth(), // Enough history to attribute changes to commits
 		})
 		if err != nil {
+			// Don't leave a partial clone behind for the next attempt to trip over
+			os.RemoveAll(repoState.localPath)
 			return fmt.Errorf("failed to clone repository %s: %w", repoState.name, err)
 		}
 
@@ -179,8 +216,10 @@ func syncRepository(repoState *gitOpsRepositoryState) error {
 		}
 
 		err = workTree.Pull(&git.PullOptions{
+			Auth:          auth,
 			ReferenceName: plumbing.ReferenceName(fmt.Sprintf("refs/heads/%s", repoState.branch)),
 			SingleBranch:  true,
+			Depth:         cloneDepth(),
 		})
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			log.Warn().
@@ -201,9 +240,10 @@ func syncRepository(repoState *gitOpsRepositoryState) error {
 			repoState.repository = nil
 			repo, err := git.PlainClone(repoState.localPath, false, &git.CloneOptions{
 				URL:           repoState.url,
+				Auth:          auth,
 				ReferenceName: plumbing.ReferenceName(fmt.Sprintf("refs/heads/%s", repoState.branch)),
 				SingleBranch:  true,
-				Depth:         1, // Shallow clone for efficiency
+				Depth:         cloneDepth(), // Enough history to attribute changes to commits
 			})
 			if err != nil {
 				return fmt.Errorf("failed to re-clone repository %s after pull failure: %w", repoState.name, err)
@@ -230,11 +270,47 @@ func syncRepository(repoState *gitOpsRepositoryState) error {
 	if currentCommit != repoState.lastCommit {
 		log.Info().
 			Str("repository", repoState.name).
-			Str("commit", currentCommit[:8]).
+			Str("commit", shortCommit(currentCommit)).
 			Msg("Repository updated to new commit")
 		repoState.lastCommit = currentCommit
 	}
 
+	commitInfo, err := readGitOpsCommit(repoState.repository, ref.Hash())
+	if err != nil {
+		log.Warn().Err(err).Str("repository", repoState.name).Msg("Failed to read commit details")
+	} else {
+		repoState.lastCommitInfo = commitInfo
+	}
+
 	repoState.lastSync = time.Now()
 	return nil
 }
+
+// gitOpsConfigMapKey turns a repository name into a valid ConfigMap data key.
+// Names that needed changes get a hash suffix, so two names can't end up on the same key.
+func gitOpsConfigMapKey(repositoryName string) string {
+	key := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, repositoryName)
+
+	// "." and ".." are the only names of valid characters a ConfigMap still refuses
+	if key == repositoryName && len(key) <= 200 && key != "." && key != ".." {
+		return key
+	}
+	if len(key) > 200 {
+		key = key[:200]
+	}
+	sum := sha256.Sum256([]byte(repositoryName))
+	return fmt.Sprintf("%s-%s", key, hex.EncodeToString(sum[:4]))
+}
+
+// shortCommit abbreviates a commit hash for logs and alerts
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
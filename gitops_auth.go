@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	log "github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GitHub App installation tokens are renewed when less than this is left
+const gitHubAppTokenRenewBefore = 5 * time.Minute
+
+// gitHubAppToken is a cached installation token, tied to the Secret version it was created from
+type gitHubAppToken struct {
+	token           string
+	expiresAt       time.Time
+	resourceVersion string
+}
+
+var (
+	gitHubAppTokens     = make(map[string]gitHubAppToken) // Keyed by repository
+	gitHubAppTokensLock sync.Mutex
+)
+
+// getGitOpsAuth builds the credentials of a repository from its Secret.
+// The Secret is read on every sync, so rotated credentials are picked up by the next one.
+func getGitOpsAuth(repoState *gitOpsRepositoryState) (transport.AuthMethod, error) {
+	auth := repoState.auth
+	if auth.Type == "" {
+		return nil, nil
+	}
+	if auth.SecretName == "" {
+		return nil, fmt.Errorf("auth type %s requires secret_name", auth.Type)
+	}
+
+	namespace := detectNamespace()
+	secret, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), auth.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Secret %s/%s: %w", namespace, auth.SecretName, err)
+	}
+
+	return getGitOpsAuthFromSecret(repoState, secret)
+}
+
+// getGitOpsAuthFromSecret builds the credentials of the repository's auth type from its Secret
+func getGitOpsAuthFromSecret(repoState *gitOpsRepositoryState, secret *corev1.Secret) (transport.AuthMethod, error) {
+	auth := repoState.auth
+
+	switch auth.Type {
+	case "ssh":
+		return getGitOpsSSHAuth(repoState, secret)
+
+	case "basic":
+		username, err := getSecretValue(secret, auth.UsernameKey, "username")
+		if err != nil {
+			return nil, err
+		}
+		password, err := getSecretValue(secret, auth.PasswordKey, "password")
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: username, Password: password}, nil
+
+	case "token":
+		token, err := getSecretValue(secret, auth.TokenKey, "token")
+		if err != nil {
+			return nil, err
+		}
+		// Git hosts ignore the username for tokens, but it must not be empty
+		username, err := getSecretValue(secret, auth.UsernameKey, "username")
+		if err != nil {
+			username = "x-access-token"
+		}
+		return &githttp.BasicAuth{Username: username, Password: token}, nil
+
+	case "github_app":
+		token, err := getGitHubAppToken(repoState.name, auth, secret)
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: "x-access-token", Password: token}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+}
+
+// getSecretValue returns a key of a Secret, falling back to the default key name
+func getSecretValue(secret *corev1.Secret, key, defaultKey string) (string, error) {
+	if key == "" {
+		key = defaultKey
+	}
+	value, exists := secret.Data[key]
+	if !exists || len(value) == 0 {
+		return "", fmt.Errorf("key %q not found in Secret %s", key, secret.Name)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// getGitOpsSSHAuth builds a deploy key auth that only trusts hosts listed in the Secret's known_hosts
+func getGitOpsSSHAuth(repoState *gitOpsRepositoryState, secret *corev1.Secret) (transport.AuthMethod, error) {
+	auth := repoState.auth
+
+	privateKey, err := getSecretValue(secret, auth.SSHPrivateKeyKey, corev1.SSHAuthPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	knownHosts, err := getSecretValue(secret, auth.KnownHostsKey, "known_hosts")
+	if err != nil {
+		return nil, fmt.Errorf("SSH host keys must be verified: %w", err)
+	}
+	passphrase, _ := getSecretValue(secret, auth.PassphraseKey, "passphrase")
+
+	endpoint, err := transport.NewEndpoint(repoState.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository URL: %w", err)
+	}
+	user := endpoint.User
+	if user == "" {
+		user = "git"
+	}
+
+	publicKeys, err := gitssh.NewPublicKeys(user, []byte(privateKey+"\n"), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH private key: %w", err)
+	}
+
+	// The known_hosts parser only reads files, keep it next to the clone under a name that is safe as a file name
+	knownHostsPath := filepath.Join(filepath.Dir(repoState.localPath), gitOpsConfigMapKey(repoState.name)+".known_hosts")
+	if err := os.WriteFile(knownHostsPath, []byte(knownHosts+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+	hostKeyDB, err := gitssh.NewKnownHostsDb(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse known_hosts: %w", err)
+	}
+
+	port := endpoint.Port
+	if port == 0 {
+		port = 22
+	}
+	publicKeys.HostKeyCallback = hostKeyDB.HostKeyCallback()
+	publicKeys.HostKeyAlgorithms = hostKeyDB.HostKeyAlgorithms(net.JoinHostPort(endpoint.Host, strconv.Itoa(port)))
+
+	return publicKeys, nil
+}
+
+// getGitHubAppToken returns an installation token for a GitHub App, reusing it until it nearly expires
+// or the Secret changes
+func getGitHubAppToken(repositoryName string, auth GitOpsAuth, secret *corev1.Secret) (string, error) {
+	gitHubAppTokensLock.Lock()
+	cached, exists := gitHubAppTokens[repositoryName]
+	gitHubAppTokensLock.Unlock()
+
+	if exists && cached.resourceVersion == secret.ResourceVersion && time.Until(cached.expiresAt) > gitHubAppTokenRenewBefore {
+		return cached.token, nil
+	}
+
+	appID, err := getSecretValue(secret, auth.AppIDKey, "app-id")
+	if err != nil {
+		return "", err
+	}
+	installationID, err := getSecretValue(secret, auth.InstallationIDKey, "installation-id")
+	if err != nil {
+		return "", err
+	}
+	privateKeyPEM, err := getSecretValue(secret, auth.AppPrivateKeyKey, "private-key")
+	if err != nil {
+		return "", err
+	}
+
+	privateKey, err := parseRSAPrivateKey([]byte(privateKeyPEM))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+
+	appJWT, err := signGitHubAppJWT(appID, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	apiURL := strings.TrimSuffix(auth.GitHubAPIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/app/installations/%s/access_tokens", apiURL, installationID), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub App token request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+appJWT)
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request GitHub App installation token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("GitHub App installation token request failed with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub App installation token: %w", err)
+	}
+
+	gitHubAppTokensLock.Lock()
+	gitHubAppTokens[repositoryName] = gitHubAppToken{
+		token:           body.Token,
+		expiresAt:       body.ExpiresAt,
+		resourceVersion: secret.ResourceVersion,
+	}
+	gitHubAppTokensLock.Unlock()
+
+	log.Debug().
+		Str("repository", repositoryName).
+		Time("expiresAt", body.ExpiresAt).
+		Msg("Created GitHub App installation token")
+
+	return body.Token, nil
+}
+
+// parseRSAPrivateKey reads the PKCS#1 key GitHub issues, or a PKCS#8 conversion of it
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// signGitHubAppJWT creates the short-lived RS256 JWT that authenticates as the GitHub App
+func signGitHubAppJWT(appID string, privateKey *rsa.PrivateKey) (string, error) {
+	now := time.Now()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(), // Allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestGitRepository creates a repository with a single commit and returns its path and HEAD
+func newTestGitRepository(t *testing.T) (string, plumbing.Hash) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to serve test repositories")
+	}
+
+	dir := filepath.Join(t.TempDir(), "repo")
+	repository, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []\n"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+	if _, err := worktree.Add("kustomization.yaml"); err != nil {
+		t.Fatalf("failed to add file: %v", err)
+	}
+	hash, err := worktree.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "sun", Email: "sun@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	return dir, hash
+}
+
+// listTestRemote returns the commit a remote's HEAD points at
+func listTestRemote(url string, auth transport.AuthMethod) (plumbing.Hash, error) {
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: "origin", URLs: []string{url}})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.HashReference {
+			return ref.Hash(), nil
+		}
+	}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			return ref.Hash(), nil
+		}
+	}
+	return plumbing.ZeroHash, nil
+}
+
+// startTestSSHServer serves git-upload-pack of a repository to clients using the given key
+func startTestSSHServer(t *testing.T, repoDir string, hostKey ssh.Signer, clientKey ssh.PublicKey) string {
+	t.Helper()
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, os.ErrPermission
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, serverConfig, repoDir)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveTestSSHConn(conn net.Conn, serverConfig *ssh.ServerConfig, repoDir string) {
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			defer channel.Close()
+			for request := range channelRequests {
+				if request.Type != "exec" {
+					request.Reply(request.Type == "env", nil)
+					continue
+				}
+				request.Reply(true, nil)
+
+				// Whatever path was asked for, serve the test repository
+				cmd := exec.Command("git", "upload-pack", repoDir)
+				cmd.Stdin = channel
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+				status := uint32(0)
+				if err := cmd.Run(); err != nil {
+					status = 1
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+func newTestSSHKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+func TestGetGitOpsSSHAuth(t *testing.T) {
+	repoDir, head := newTestGitRepository(t)
+
+	hostKey, _ := newTestSSHKey(t)
+	otherHostKey, _ := newTestSSHKey(t)
+	clientKey, clientKeyPEM := newTestSSHKey(t)
+	address := startTestSSHServer(t, repoDir, hostKey, clientKey.PublicKey())
+
+	tempDir := t.TempDir()
+	repoState := &gitOpsRepositoryState{
+		name:      "team/apps",
+		url:       "ssh://git@" + address + "/apps.git",
+		localPath: filepath.Join(tempDir, gitOpsConfigMapKey("team/apps")),
+		auth:      GitOpsAuth{Type: "ssh", SecretName: "git"},
+	}
+
+	newSecret := func(hostKey ssh.PublicKey) *corev1.Secret {
+		data := map[string][]byte{corev1.SSHAuthPrivateKey: clientKeyPEM}
+		if hostKey != nil {
+			data["known_hosts"] = []byte(knownhosts.Line([]string{knownhosts.Normalize(address)}, hostKey))
+		}
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "git"}, Data: data}
+	}
+
+	t.Run("trusted host", func(t *testing.T) {
+		auth, err := getGitOpsAuthFromSecret(repoState, newSecret(hostKey.PublicKey()))
+		if err != nil {
+			t.Fatalf("getGitOpsAuthFromSecret() error = %v", err)
+		}
+
+		got, err := listTestRemote(repoState.url, auth)
+		if err != nil {
+			t.Fatalf("listing the remote failed: %v", err)
+		}
+		if got != head {
+			t.Errorf("remote HEAD = %s, want %s", got, head)
+		}
+
+		// The repository name must not leave the temp directory or create subdirectories
+		if _, err := os.Stat(filepath.Join(tempDir, gitOpsConfigMapKey(repoState.name)+".known_hosts")); err != nil {
+			t.Errorf("known_hosts wasn't written next to the clone: %v", err)
+		}
+	})
+
+	t.Run("unknown host key", func(t *testing.T) {
+		auth, err := getGitOpsAuthFromSecret(repoState, newSecret(otherHostKey.PublicKey()))
+		if err != nil {
+			t.Fatalf("getGitOpsAuthFromSecret() error = %v", err)
+		}
+		if _, err := listTestRemote(repoState.url, auth); err == nil {
+			t.Error("listing the remote succeeded with a host key missing from known_hosts")
+		}
+	})
+
+	t.Run("missing known_hosts", func(t *testing.T) {
+		if _, err := getGitOpsAuthFromSecret(repoState, newSecret(nil)); err == nil {
+			t.Error("getGitOpsAuthFromSecret() accepted a Secret without known_hosts")
+		}
+	})
+}
+
+func TestGetGitOpsBasicAuth(t *testing.T) {
+	repoDir, head := newTestGitRepository(t)
+
+	gitPath, _ := exec.LookPath("git")
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + filepath.Dir(repoDir),
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "sun" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	repoState := &gitOpsRepositoryState{
+		name: "apps",
+		url:  server.URL + "/" + filepath.Base(repoDir) + "/.git",
+		auth: GitOpsAuth{Type: "basic", SecretName: "git"},
+	}
+
+	newSecret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "git"},
+			Data: map[string][]byte{
+				"username": []byte("sun"),
+				"password": []byte(password + "\n"), // Secrets created from files often end in a newline
+			},
+		}
+	}
+
+	t.Run("valid credentials", func(t *testing.T) {
+		auth, err := getGitOpsAuthFromSecret(repoState, newSecret("secret"))
+		if err != nil {
+			t.Fatalf("getGitOpsAuthFromSecret() error = %v", err)
+		}
+		got, err := listTestRemote(repoState.url, auth)
+		if err != nil {
+			t.Fatalf("listing the remote failed: %v", err)
+		}
+		if got != head {
+			t.Errorf("remote HEAD = %s, want %s", got, head)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		auth, err := getGitOpsAuthFromSecret(repoState, newSecret("wrong"))
+		if err != nil {
+			t.Fatalf("getGitOpsAuthFromSecret() error = %v", err)
+		}
+		if _, err := listTestRemote(repoState.url, auth); err == nil {
+			t.Error("listing the remote succeeded with a wrong password")
+		}
+	})
+
+	t.Run("missing password", func(t *testing.T) {
+		secret := newSecret("secret")
+		delete(secret.Data, "password")
+		if _, err := getGitOpsAuthFromSecret(repoState, secret); err == nil {
+			t.Error("getGitOpsAuthFromSecret() accepted a Secret without a password")
+		}
+	})
+}
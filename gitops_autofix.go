@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Field manager used for both the dry-run comparison and auto-fix applies
+const gitOpsFieldManager = "sun-gitops"
+
+var (
+	// Timestamps of auto-fixes applied within the last hour, across all repositories
+	gitOpsAutoFixHistory []time.Time
+	// Last auto-fix per GitOps state key, to avoid fighting other controllers over a resource
+	gitOpsAutoFixLast = make(map[string]time.Time)
+	gitOpsAutoFixLock sync.Mutex
+)
+
+// isGitOpsAutoFixEnabled reports whether a resource of a repository may be fixed automatically.
+// An empty kind list allows every kind, like the GitOps allowlist.
+func isGitOpsAutoFixEnabled(repoState *gitOpsRepositoryState, kind string) bool {
+	if !config.GitOps.AutoFix.Enabled || !repoState.autoFix {
+		return false
+	}
+
+	// Only the leader writes to the cluster
+	leaderLock.RLock()
+	leader := isLeader
+	leaderLock.RUnlock()
+	if !leader {
+		return false
+	}
+
+	if len(config.GitOps.AutoFix.Kinds) == 0 {
+		return true
+	}
+	for _, allowedKind := range config.GitOps.AutoFix.Kinds {
+		if strings.EqualFold(allowedKind, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// reserveGitOpsAutoFix records an auto-fix attempt if the hourly limit and the per-resource cooldown allow it
+func reserveGitOpsAutoFix(key string) (bool, string) {
+	gitOpsAutoFixLock.Lock()
+	defer gitOpsAutoFixLock.Unlock()
+
+	now := time.Now()
+
+	cooldown := time.Duration(config.GitOps.AutoFix.CooldownMinutes) * time.Minute
+	if last, exists := gitOpsAutoFixLast[key]; exists && now.Sub(last) < cooldown {
+		return false, fmt.Sprintf("resource was auto-fixed %s ago", now.Sub(last).Round(time.Second))
+	}
+
+	recent := gitOpsAutoFixHistory[:0]
+	for _, appliedAt := range gitOpsAutoFixHistory {
+		if now.Sub(appliedAt) < time.Hour {
+			recent = append(recent, appliedAt)
+		}
+	}
+	gitOpsAutoFixHistory = recent
+
+	if config.GitOps.AutoFix.MaxPerHour > 0 && len(gitOpsAutoFixHistory) >= config.GitOps.AutoFix.MaxPerHour {
+		return false, fmt.Sprintf("limit of %d auto-fixes per hour reached", config.GitOps.AutoFix.MaxPerHour)
+	}
+
+	gitOpsAutoFixHistory = append(gitOpsAutoFixHistory, now)
+	gitOpsAutoFixLast[key] = now
+	return true, ""
+}
+
+// tryGitOpsAutoFix applies the Git manifest with server-side apply and verifies the result.
+// It returns true when the resource is in sync afterwards, so no mismatch has to be reported.
+func tryGitOpsAutoFix(repoState *gitOpsRepositoryState, manifest, clusterResource *unstructured.Unstructured, mismatchType string) bool {
+	kind := manifest.GetKind()
+	name := manifest.GetName()
+	namespace := manifest.GetNamespace()
+
+	if !isGitOpsAutoFixEnabled(repoState, kind) {
+		return false
+	}
+
+	key := fmt.Sprintf("%s/%s/%s/%s", repoState.name, namespace, kind, name)
+	if ok, reason := reserveGitOpsAutoFix(key); !ok {
+		log.Warn().
+			Str("repository", repoState.name).
+			Str("kind", kind).
+			Str("name", name).
+			Str("namespace", namespace).
+			Str("reason", reason).
+			Msg("Skipping GitOps auto-fix")
+		return false
+	}
+
+	resourceClient, err := getResourceClient(manifest)
+	if err != nil {
+		log.Error().Err(err).Str("kind", kind).Msg("Failed to resolve resource for GitOps auto-fix")
+		return false
+	}
+
+	applied, err := resourceClient.Apply(context.TODO(), name, manifest, metav1.ApplyOptions{
+		FieldManager: gitOpsFieldManager,
+		Force:        true,
+	})
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("repository", repoState.name).
+			Str("kind", kind).
+			Str("name", name).
+			Str("namespace", namespace).
+			Msg("GitOps auto-fix apply failed")
+		sendGitOpsAutoFixAlert(repoState.name, manifest, mismatchType, nil, err, false)
+		return false
+	}
+
+	log.Info().
+		Str("repository", repoState.name).
+		Str("kind", kind).
+		Str("name", name).
+		Str("namespace", namespace).
+		Str("mismatchType", mismatchType).
+		Msg("GitOps auto-fix applied")
+
+	// Verify with the same comparison that detected the mismatch
+	different, _, err := resourcesAreDifferent(repoState.name, manifest, applied)
+	inSync := err == nil && !different
+
+	sendGitOpsAutoFixAlert(repoState.name, manifest, mismatchType, describeGitOpsChanges(clusterResource, applied), nil, inSync)
+
+	return inSync
+}
+
+// describeGitOpsChanges lists the parts of a resource an apply changed
+func describeGitOpsChanges(before, after *unstructured.Unstructured) []string {
+	if before == nil {
+		return []string{"created resource"}
+	}
+
+	var changes []string
+	for _, path := range [][]string{
+		{"spec"},
+		{"data"},
+		{"stringData"},
+		{"rules"},
+		{"webhooks"},
+		{"metadata", "labels"},
+		{"metadata", "annotations"},
+	} {
+		beforeValue, _, _ := unstructured.NestedFieldNoCopy(before.Object, path...)
+		afterValue, _, _ := unstructured.NestedFieldNoCopy(after.Object, path...)
+
+		beforeJSON, _ := json.Marshal(beforeValue)
+		afterJSON, _ := json.Marshal(afterValue)
+		if string(beforeJSON) != string(afterJSON) {
+			changes = append(changes, strings.Join(path, "."))
+		}
+	}
+
+	if len(changes) == 0 {
+		changes = append(changes, "no visible changes")
+	}
+	return changes
+}
+
+// sendGitOpsAutoFixAlert reports an auto-fix and whether the resource is in sync afterwards
+func sendGitOpsAutoFixAlert(repositoryName string, manifest *unstructured.Unstructured, mismatchType string, changes []string, applyErr error, inSync bool) {
+	kind := manifest.GetKind()
+	name := manifest.GetName()
+	namespace := manifest.GetNamespace()
+
+	var title, description, status string
+	switch {
+	case applyErr != nil:
+		title = fmt.Sprintf("GitOps Auto-Fix Failed in %s", repositoryName)
+		description = fmt.Sprintf("Failed to apply %s/%s from Git", kind, name)
+		status = "❌ Apply Failed"
+	case inSync:
+		title = fmt.Sprintf("GitOps Auto-Fix Applied in %s", repositoryName)
+		description = fmt.Sprintf("Applied %s/%s from Git with server-side apply", kind, name)
+		status = "✅ In Sync"
+	default:
+		title = fmt.Sprintf("GitOps Auto-Fix Incomplete in %s", repositoryName)
+		description = fmt.Sprintf("Applied %s/%s from Git, but it still differs from the cluster", kind, name)
+		status = "⚠️ Still Differs"
+	}
+
+	alert := Alert{
+		Title:       title,
+		Description: description,
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Repository", Value: repositoryName, Inline: true},
+			{Name: "Resource Kind", Value: kind, Inline: true},
+			{Name: "Resource Name", Value: name, Inline: true},
+		},
+	}
+
+	if namespace != "" {
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Namespace", Value: namespace, Inline: true})
+	}
+
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "Mismatch Type", Value: mismatchType, Inline: true})
+
+	if len(changes) > 0 {
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Changed", Value: strings.Join(changes, ", "), Inline: false})
+	}
+
+	if applyErr != nil {
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Error", Value: alertFieldValue(applyErr.Error()), Inline: false})
+	}
+
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "Status", Value: status, Inline: true})
+
+	appendGitOpsCommitField(&alert, repositoryName)
+
+	sendWebhookMessage(alert)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// gitOpsCommit is the part of a commit shown in alerts
+type gitOpsCommit struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Subject string
+}
+
+func newGitOpsCommit(commit *object.Commit) gitOpsCommit {
+	subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+	return gitOpsCommit{
+		Hash:    commit.Hash.String(),
+		Author:  commit.Author.Name,
+		Date:    commit.Author.When,
+		Subject: subject,
+	}
+}
+
+// String renders the commit for an alert field
+func (c gitOpsCommit) String() string {
+	return fmt.Sprintf("%s %s\n%s, %s", shortCommit(c.Hash), c.Subject, c.Author, c.Date.UTC().Format(time.RFC3339))
+}
+
+// Source files of rendered manifests, relative to the repository root and keyed like gitOpsStates
+var (
+	gitOpsManifestSources     = make(map[string]string)
+	gitOpsManifestSourcesLock sync.RWMutex
+)
+
+// originTrackingFS enables origin annotations on the root kustomization, so every rendered resource
+// can be traced back to the file it came from. The clone on disk is left untouched.
+type originTrackingFS struct {
+	filesys.FileSystem
+	root     string
+	injected bool // The kustomization didn't ask for origin annotations itself
+}
+
+func newOriginTrackingFS(fSys filesys.FileSystem, root string) *originTrackingFS {
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return &originTrackingFS{FileSystem: fSys, root: root}
+}
+
+func (fs *originTrackingFS) ReadFile(path string) ([]byte, error) {
+	data, err := fs.FileSystem.ReadFile(path)
+	if err != nil || !fs.isRootKustomization(path) {
+		return data, err
+	}
+
+	var kustomization map[string]interface{}
+	if err := yaml.Unmarshal(data, &kustomization); err != nil || kustomization == nil {
+		// Let kustomize report the error
+		return data, nil
+	}
+
+	buildMetadata, _ := kustomization["buildMetadata"].([]interface{})
+	for _, option := range buildMetadata {
+		if option == "originAnnotations" {
+			return data, nil
+		}
+	}
+	kustomization["buildMetadata"] = append(buildMetadata, "originAnnotations")
+
+	modified, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return data, nil
+	}
+	fs.injected = true
+	return modified, nil
+}
+
+func (fs *originTrackingFS) isRootKustomization(path string) bool {
+	dir := filepath.Dir(path)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if dir != fs.root {
+		return false
+	}
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if filepath.Base(path) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getResourceSource returns the repository-relative file a rendered resource came from.
+// Generated resources point at the kustomization that configured them.
+func getResourceSource(res *resource.Resource, kustomizePath, localPath string) string {
+	origin, err := res.GetOrigin()
+	if err != nil || origin == nil || origin.Repo != "" {
+		// Resources from remote bases have no file in this repository
+		return ""
+	}
+
+	path := origin.Path
+	if origin.ConfiguredIn != "" {
+		path = origin.ConfiguredIn
+	}
+	if path == "" {
+		return ""
+	}
+
+	relative, err := filepath.Rel(localPath, filepath.Join(kustomizePath, path))
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return ""
+	}
+	return filepath.ToSlash(relative)
+}
+
+// setGitOpsManifestSources replaces the recorded source files of a repository's manifests
+func setGitOpsManifestSources(repositoryName string, sources map[string]string) {
+	gitOpsManifestSourcesLock.Lock()
+	defer gitOpsManifestSourcesLock.Unlock()
+
+	for key := range gitOpsManifestSources {
+		if strings.HasPrefix(key, repositoryName+"/") {
+			delete(gitOpsManifestSources, key)
+		}
+	}
+	for key, source := range sources {
+		gitOpsManifestSources[key] = source
+	}
+}
+
+func getGitOpsManifestSource(repositoryName string, manifest *unstructured.Unstructured) string {
+	gitOpsManifestSourcesLock.RLock()
+	defer gitOpsManifestSourcesLock.RUnlock()
+
+	if source, exists := gitOpsManifestSources[gitOpsStateKey(repositoryName, manifest)]; exists {
+		return source
+	}
+	// Rendered without a namespace, which is defaulted once the resource is resolved
+	return gitOpsManifestSources[fmt.Sprintf("%s//%s/%s", repositoryName, manifest.GetKind(), manifest.GetName())]
+}
+
+// getGitOpsRepositoryState looks up a configured repository by name
+func getGitOpsRepositoryState(repositoryName string) *gitOpsRepositoryState {
+	gitOpsRepositoriesLock.RLock()
+	defer gitOpsRepositoriesLock.RUnlock()
+
+	return gitOpsRepositories[repositoryName]
+}
+
+// getGitOpsRenderedCommit returns the commit a repository's manifests were last rendered from
+func getGitOpsRenderedCommit(repositoryName string) (gitOpsCommit, bool) {
+	repoState := getGitOpsRepositoryState(repositoryName)
+	if repoState == nil {
+		return gitOpsCommit{}, false
+	}
+
+	repoState.mutex.RLock()
+	defer repoState.mutex.RUnlock()
+
+	return repoState.lastCommitInfo, repoState.lastCommitInfo.Hash != ""
+}
+
+// findLastCommitTouching walks the first-parent history from HEAD and returns the newest commit
+// that changed a path. It returns false when the change is older than the fetched history.
+func findLastCommitTouching(repoState *gitOpsRepositoryState, path string) (gitOpsCommit, bool, error) {
+	repoState.mutex.RLock()
+	defer repoState.mutex.RUnlock()
+
+	if repoState.repository == nil {
+		return gitOpsCommit{}, false, fmt.Errorf("repository %s is not cloned", repoState.name)
+	}
+
+	head, err := repoState.repository.Head()
+	if err != nil {
+		return gitOpsCommit{}, false, err
+	}
+	commit, err := repoState.repository.CommitObject(head.Hash())
+	if err != nil {
+		return gitOpsCommit{}, false, err
+	}
+
+	currentHash, err := pathHashAt(commit, path)
+	if err != nil {
+		return gitOpsCommit{}, false, err
+	}
+
+	for {
+		if commit.NumParents() == 0 {
+			// The root commit added the path, unless it never existed
+			return newGitOpsCommit(commit), currentHash != plumbing.ZeroHash, nil
+		}
+
+		parent, err := commit.Parent(0)
+		if err == plumbing.ErrObjectNotFound {
+			// Reached the shallow clone boundary
+			return gitOpsCommit{}, false, nil
+		}
+		if err != nil {
+			return gitOpsCommit{}, false, err
+		}
+
+		parentHash, err := pathHashAt(parent, path)
+		if err != nil {
+			return gitOpsCommit{}, false, err
+		}
+		if parentHash != currentHash {
+			return newGitOpsCommit(commit), true, nil
+		}
+
+		commit = parent
+	}
+}
+
+// pathHashAt returns the hash of a file or directory in a commit, or the zero hash when it doesn't exist
+func pathHashAt(commit *object.Commit, path string) (plumbing.Hash, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	entry, err := tree.FindEntry(path)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return entry.Hash, nil
+}
+
+// appendGitOpsCommitField adds the rendered revision of a repository to an alert
+func appendGitOpsCommitField(alert *Alert, repositoryName string) {
+	commit, exists := getGitOpsRenderedCommit(repositoryName)
+	if !exists {
+		return
+	}
+
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "Commit", Value: alertFieldValue(commit.String()), Inline: false})
+}
+
+// appendGitOpsSourceField adds the source file of a manifest and the commit that last changed it
+func appendGitOpsSourceField(alert *Alert, repositoryName string, manifest *unstructured.Unstructured) {
+	source := getGitOpsManifestSource(repositoryName, manifest)
+	repoState := getGitOpsRepositoryState(repositoryName)
+	if source == "" || repoState == nil {
+		return
+	}
+
+	value := source
+	commit, found, err := findLastCommitTouching(repoState, source)
+	switch {
+	case err != nil:
+		log.Debug().
+			Err(err).
+			Str("repository", repositoryName).
+			Str("source", source).
+			Msg("Failed to find the last commit of a GitOps source")
+	case found:
+		value = fmt.Sprintf("%s\nLast changed in %s", source, commit.String())
+	default:
+		value = fmt.Sprintf("%s\nLast changed before the fetched history (history_depth: %d)", source, config.GitOps.HistoryDepth)
+	}
+
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "Source", Value: alertFieldValue(value), Inline: false})
+}
+
+// cloneDepth returns the history depth used for clones and pulls, 0 fetches the full history
+func cloneDepth() int {
+	if config.GitOps.HistoryDepth < 0 {
+		return 0
+	}
+	return config.GitOps.HistoryDepth
+}
+
+// readGitOpsCommit loads the commit HEAD points at
+func readGitOpsCommit(repository *git.Repository, hash plumbing.Hash) (gitOpsCommit, error) {
+	commit, err := repository.CommitObject(hash)
+	if err != nil {
+		return gitOpsCommit{}, err
+	}
+	return newGitOpsCommit(commit), nil
+}
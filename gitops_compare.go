@@ -2,23 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"strings"
-	"sync"
 
 	log "github.com/rs/zerolog/log"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-)
-
-// Cache for discovered GVR mappings
-var (
-	gvrCache     = make(map[string]schema.GroupVersionResource)
-	gvrCacheLock sync.RWMutex
-	gvrCacheInit sync.Once
+	"k8s.io/client-go/dynamic"
 )
 
 // compareManifests compares generated manifests with live cluster resources
@@ -28,8 +18,10 @@ func compareManifests(repoState *gitOpsRepositoryState) error {
 	// Generate manifests using Kustomize
 	manifests, err := generateKustomizeManifests(repoState)
 	if err != nil {
+		processGitOpsRenderFailure(repoState, err)
 		return fmt.Errorf("failed to generate manifests for repository %s: %w", repoState.name, err)
 	}
+	processGitOpsRenderSuccess(repoState)
 
 	log.Debug().
 		Str("repository", repoState.name).
@@ -47,11 +39,25 @@ func compareManifests(repoState *gitOpsRepositoryState) error {
 		}
 	}
 
+	pruneGitOpsRejectionStates(repoState.name, manifests)
+
+	if config.GitOps.DetectExtraResources {
+		if err := detectExtraResources(repoState, manifests); err != nil {
+			log.Error().Err(err).Str("repository", repoState.name).Msg("Failed to detect extra resources")
+		}
+	}
+
 	return nil
 }
 
 // compareManifestWithCluster compares a single manifest with its cluster counterpart
 func compareManifestWithCluster(repoState *gitOpsRepositoryState, manifest *unstructured.Unstructured) error {
+	// Resolve the resource first, it also settles the namespace from the discovered scope
+	resourceClient, err := getResourceClient(manifest)
+	if err != nil {
+		return err
+	}
+
 	kind := manifest.GetKind()
 	name := manifest.GetName()
 	namespace := manifest.GetNamespace()
@@ -63,84 +69,92 @@ func compareManifestWithCluster(repoState *gitOpsRepositoryState, manifest *unst
 		Str("namespace", namespace).
 		Msg("Comparing manifest with cluster")
 
-	// Get the GroupVersionResource for this resource
-	gvr, err := getGVRForKind(kind)
-	if err != nil {
-		return fmt.Errorf("failed to get GVR for kind %s: %w", kind, err)
-	}
-
 	// Get the resource from the cluster
-	var clusterResource *unstructured.Unstructured
-	if namespace != "" {
-		// Namespaced resource
-		clusterResource, err = dynamicClient.Resource(gvr).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
-	} else {
-		// Cluster-scoped resource
-		clusterResource, err = dynamicClient.Resource(gvr).Get(context.TODO(), name, metav1.GetOptions{})
-	}
-
+	clusterResource, err := resourceClient.Get(context.TODO(), name, metav1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
-			// Resource is missing from cluster
+			// Resource is missing from cluster, check it could be created at all
+			_, applyErr := dryRunApply(resourceClient, manifest)
+			switch {
+			case applyErr == nil:
+				processGitOpsAccepted(repoState, manifest)
+			case isApplyRejection(applyErr):
+				processGitOpsRejection(repoState, manifest, applyErr)
+				// Creating it would be rejected just the same, so don't try to fix it
+				return processGitOpsMismatch(repoState, manifest, nil, "missing")
+			default:
+				// The API server couldn't be asked, keep any previous rejection until it can
+				log.Debug().
+					Err(applyErr).
+					Str("repository", repoState.name).
+					Str("kind", kind).
+					Str("name", name).
+					Msg("Failed to dry-run a missing resource")
+			}
+
+			if tryGitOpsAutoFix(repoState, manifest, nil, "missing") {
+				return processGitOpsMatch(repoState, manifest)
+			}
 			return processGitOpsMismatch(repoState, manifest, nil, "missing")
 		}
 		return fmt.Errorf("failed to get resource %s/%s from cluster: %w", kind, name, err)
 	}
 
 	// Compare the resources
-	if resourcesAreDifferent(manifest, clusterResource) {
-		return processGitOpsMismatch(repoState, manifest, clusterResource, "different")
+	different, desired, err := resourcesAreDifferent(repoState.name, manifest, clusterResource)
+	if err != nil {
+		// A rejected manifest can't be compared, keep the previous mismatch state until it's fixed
+		processGitOpsRejection(repoState, manifest, err)
+		return nil
+	}
+	// Without a dry-run result the API server wasn't asked, so it hasn't accepted the manifest either
+	if desired != nil {
+		processGitOpsAccepted(repoState, manifest)
+	}
+
+	if different {
+		if tryGitOpsAutoFix(repoState, manifest, clusterResource, "different") {
+			return processGitOpsMatch(repoState, manifest)
+		}
+		// The dry-run result is what applying Git would make of the resource, which is what the diff needs
+		return processGitOpsMismatch(repoState, desired, clusterResource, "different")
 	}
 
 	// Resources match - clear any previous error state
 	return processGitOpsMatch(repoState, manifest)
 }
 
-// resourcesAreDifferent compares two unstructured resources using server-side apply dry-run
-func resourcesAreDifferent(expected, actual *unstructured.Unstructured) bool {
-	// Get the GroupVersionResource for this resource
-	gvr, err := getGVRForKind(expected.GetKind())
+// resourcesAreDifferent compares two unstructured resources using server-side apply dry-run,
+// skipping fields the repository's ignore_differences rules exclude.
+// It also returns the dry-run result, the resource as it would be after applying the expected manifest,
+// and the API server's error when it would reject the manifest.
+func resourcesAreDifferent(repositoryName string, expected, actual *unstructured.Unstructured) (bool, *unstructured.Unstructured, error) {
+	resourceClient, err := getResourceClient(expected)
 	if err != nil {
-		log.Error().Err(err).Str("kind", expected.GetKind()).Msg("Failed to get GVR for resource comparison")
-		return false // If we can't get GVR, assume no difference to avoid false positives
+		log.Error().Err(err).Str("kind", expected.GetKind()).Msg("Failed to resolve resource for comparison")
+		return false, nil, nil // If we can't resolve the resource, assume no difference to avoid false positives
 	}
 
 	// Perform server-side apply dry-run to see if there would be changes
 	// This is exactly what kubectl diff does internally
-	var result *unstructured.Unstructured
-
-	if expected.GetNamespace() != "" {
-		// Namespaced resource
-		result, err = dynamicClient.Resource(gvr).Namespace(expected.GetNamespace()).
-			Apply(context.TODO(), expected.GetName(), expected, metav1.ApplyOptions{
-				DryRun:       []string{metav1.DryRunAll},
-				FieldManager: "sun-gitops",
-				Force:        true,
-			})
-	} else {
-		// Cluster-scoped resource
-		result, err = dynamicClient.Resource(gvr).
-			Apply(context.TODO(), expected.GetName(), expected, metav1.ApplyOptions{
-				DryRun:       []string{metav1.DryRunAll},
-				FieldManager: "sun-gitops",
-				Force:        true,
-			})
-	}
-
+	result, err := dryRunApply(resourceClient, expected)
 	if err != nil {
+		if isApplyRejection(err) {
+			return false, nil, err
+		}
 		log.Error().
 			Err(err).
 			Str("kind", expected.GetKind()).
 			Str("name", expected.GetName()).
 			Str("namespace", expected.GetNamespace()).
 			Msg("Failed to perform server-side apply dry-run")
-		return false // If dry-run fails, assume no difference to avoid false positives
+		return false, nil, nil // If the API server can't be asked, assume no difference to avoid false positives
 	}
 
 	// Compare the spec and metadata of the dry-run result with the actual resource
 	// The dry-run result shows what the resource would look like after applying the expected manifest
 	// If it's different from the actual resource, there's drift
-	different := !resourcesEqual(result, actual)
+	different := !resourcesEqual(applyGitOpsIgnoreDifferences(repositoryName, result), applyGitOpsIgnoreDifferences(repositoryName, actual))
 
 	if different {
 		log.Debug().
@@ -149,189 +163,83 @@ func resourcesAreDifferent(expected, actual *unstructured.Unstructured) bool {
 			Str("namespace", expected.GetNamespace()).
 			Msg("Server-side apply dry-run detected differences")
 
-		// Log the differences for debugging
+		// Log the differences for debugging, with Secret values masked
 		if log.Debug().Enabled() {
-			resultJSON, _ := json.MarshalIndent(result.Object, "", "  ")
-			actualJSON, _ := json.MarshalIndent(actual.Object, "", "  ")
-			log.Debug().
-				Str("kind", expected.GetKind()).
-				Str("name", expected.GetName()).
-				Str("dryRunResult", string(resultJSON)).
-				Str("actualResource", string(actualJSON)).
-				Msg("Dry-run vs actual resource comparison")
+			if diff := computeGitOpsDiff(repositoryName, result, actual); diff != nil {
+				log.Debug().
+					Str("kind", expected.GetKind()).
+					Str("name", expected.GetName()).
+					Str("diff", diff.Unified).
+					Msg("Dry-run vs actual resource comparison")
+			}
 		}
 	}
 
-	return different
+	return different, result, nil
 }
 
-// resourcesEqual compares the meaningful parts of two resources
+// dryRunApply applies a manifest with server-side apply without persisting it
+func dryRunApply(resourceClient dynamic.ResourceInterface, manifest *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return resourceClient.Apply(context.TODO(), manifest.GetName(), manifest, metav1.ApplyOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		FieldManager: gitOpsFieldManager,
+		Force:        true,
+	})
+}
+
+// resourcesEqual compares the parts of two resources the Git manifest sets.
+// Owned fields come from the dry-run's managedFields entry, so fields set by controllers,
+// autoscalers or admission webhooks aren't reported as drift.
 func resourcesEqual(dryRunResult, actual *unstructured.Unstructured) bool {
 	if dryRunResult == nil || actual == nil {
 		return dryRunResult == actual
 	}
 
-	// Compare the spec sections - this is where the actual configuration lives
-	dryRunSpec, dryRunSpecExists, _ := unstructured.NestedMap(dryRunResult.Object, "spec")
-	actualSpec, actualSpecExists, _ := unstructured.NestedMap(actual.Object, "spec")
-
-	if dryRunSpecExists != actualSpecExists {
-		return false
+	if owned, ok := getGitOpsOwnedFields(dryRunResult); ok {
+		return jsonEqual(projectGitOpsObject(dryRunResult, owned).Object, projectGitOpsObject(actual, owned).Object)
 	}
 
-	if dryRunSpecExists {
-		dryRunSpecJSON, _ := json.Marshal(dryRunSpec)
-		actualSpecJSON, _ := json.Marshal(actualSpec)
-		if string(dryRunSpecJSON) != string(actualSpecJSON) {
-			return false
-		}
-	}
+	// Without managedFields compare the whole content, which is spec for most kinds
+	// and data, rules or webhooks for ConfigMaps, Secrets, RBAC and webhook configurations
+	for _, field := range gitOpsContentFields {
+		dryRunContent, dryRunContentExists, _ := unstructured.NestedFieldNoCopy(dryRunResult.Object, field)
+		actualContent, actualContentExists, _ := unstructured.NestedFieldNoCopy(actual.Object, field)
 
-	// Compare relevant metadata (labels and annotations that aren't system-managed)
-	dryRunMeta, dryRunMetaExists, _ := unstructured.NestedMap(dryRunResult.Object, "metadata")
-	actualMeta, actualMetaExists, _ := unstructured.NestedMap(actual.Object, "metadata")
-
-	if dryRunMetaExists && actualMetaExists {
-		// Compare labels (excluding system-managed ones)
-		dryRunLabels, _, _ := unstructured.NestedStringMap(dryRunMeta, "labels")
-		actualLabels, _, _ := unstructured.NestedStringMap(actualMeta, "labels")
-
-		// Remove system-managed labels for comparison
-		cleanLabels := func(labels map[string]string) map[string]string {
-			cleaned := make(map[string]string)
-			for k, v := range labels {
-				// Skip system-managed labels
-				if k == "app.kubernetes.io/managed-by" ||
-					k == "helm.sh/chart" ||
-					k == "app.kubernetes.io/instance" ||
-					k == "app.kubernetes.io/version" {
-					continue
-				}
-				cleaned[k] = v
-			}
-			return cleaned
-		}
-
-		cleanedDryRunLabels := cleanLabels(dryRunLabels)
-		cleanedActualLabels := cleanLabels(actualLabels)
-
-		dryRunLabelsJSON, _ := json.Marshal(cleanedDryRunLabels)
-		actualLabelsJSON, _ := json.Marshal(cleanedActualLabels)
-		if string(dryRunLabelsJSON) != string(actualLabelsJSON) {
+		if dryRunContentExists != actualContentExists {
 			return false
 		}
-
-		// Compare annotations (excluding system-managed ones)
-		dryRunAnnotations, _, _ := unstructured.NestedStringMap(dryRunMeta, "annotations")
-		actualAnnotations, _, _ := unstructured.NestedStringMap(actualMeta, "annotations")
-
-		// Remove system-managed annotations for comparison
-		cleanAnnotations := func(annotations map[string]string) map[string]string {
-			cleaned := make(map[string]string)
-			for k, v := range annotations {
-				// Skip system-managed annotations
-				if k == "kubectl.kubernetes.io/last-applied-configuration" ||
-					k == "deployment.kubernetes.io/revision" ||
-					k == "meta.helm.sh/release-name" ||
-					k == "meta.helm.sh/release-namespace" {
-					continue
-				}
-				cleaned[k] = v
-			}
-			return cleaned
-		}
-
-		cleanedDryRunAnnotations := cleanAnnotations(dryRunAnnotations)
-		cleanedActualAnnotations := cleanAnnotations(actualAnnotations)
-
-		dryRunAnnotationsJSON, _ := json.Marshal(cleanedDryRunAnnotations)
-		actualAnnotationsJSON, _ := json.Marshal(cleanedActualAnnotations)
-		if string(dryRunAnnotationsJSON) != string(actualAnnotationsJSON) {
+		if dryRunContentExists && !jsonEqual(dryRunContent, actualContent) {
 			return false
 		}
 	}
 
-	return true
-}
-
-// initializeGVRCache initializes the GVR cache using discovery client
-func initializeGVRCache() {
-	log.Debug().Msg("Initializing GVR cache using discovery client")
-
-	// Create discovery client using the existing Kubernetes client
-	discoveryClient := client.Discovery()
-
-	// Get server resources
-	apiResourceLists, err := discoveryClient.ServerPreferredResources()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to get server resources")
-		return
-	}
-
-	gvrCacheLock.Lock()
-	defer gvrCacheLock.Unlock()
-
-	resourceCount := 0
-	for _, apiResourceList := range apiResourceLists {
-		if apiResourceList == nil {
-			continue
-		}
-
-		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
-		if err != nil {
-			log.Warn().Err(err).Str("groupVersion", apiResourceList.GroupVersion).Msg("Failed to parse group version")
-			continue
-		}
-
-		for _, apiResource := range apiResourceList.APIResources {
-			// Skip subresources (they contain '/')
-			if strings.Contains(apiResource.Name, "/") {
-				continue
-			}
-
-			gvr := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: apiResource.Name,
-			}
-
-			gvrCache[apiResource.Kind] = gvr
-			resourceCount++
-
-			log.Debug().
-				Str("kind", apiResource.Kind).
-				Str("group", gv.Group).
-				Str("version", gv.Version).
-				Str("resource", apiResource.Name).
-				Bool("namespaced", apiResource.Namespaced).
-				Msg("Cached GVR mapping")
-		}
+	// Compare labels and annotations, system-managed ones are excluded by the ignore rules
+	dryRunLabels, _, _ := unstructured.NestedStringMap(dryRunResult.Object, "metadata", "labels")
+	actualLabels, _, _ := unstructured.NestedStringMap(actual.Object, "metadata", "labels")
+	if !jsonEqual(dryRunLabels, actualLabels) {
+		return false
 	}
 
-	log.Info().Int("resourceCount", resourceCount).Msg("Successfully initialized GVR cache from discovery")
-}
-
-// getGVRForKind returns the GroupVersionResource for a given Kind using discovery
-func getGVRForKind(kind string) (schema.GroupVersionResource, error) {
-	// Initialize cache once
-	gvrCacheInit.Do(initializeGVRCache)
-
-	gvrCacheLock.RLock()
-	gvr, exists := gvrCache[kind]
-	gvrCacheLock.RUnlock()
-
-	if !exists {
-		return schema.GroupVersionResource{}, fmt.Errorf("unknown kind: %s", kind)
+	dryRunAnnotations, _, _ := unstructured.NestedStringMap(dryRunResult.Object, "metadata", "annotations")
+	actualAnnotations, _, _ := unstructured.NestedStringMap(actual.Object, "metadata", "annotations")
+	if !jsonEqual(dryRunAnnotations, actualAnnotations) {
+		return false
 	}
 
-	return gvr, nil
+	return true
 }
 
 // processGitOpsMismatch handles when a resource doesn't match between Git and cluster
 func processGitOpsMismatch(repoState *gitOpsRepositoryState, expected, actual *unstructured.Unstructured, mismatchType string) error {
-	kind := expected.GetKind()
-	name := expected.GetName()
-	namespace := expected.GetNamespace()
+	// Extra resources only exist in the cluster
+	resource := expected
+	if resource == nil {
+		resource = actual
+	}
+
+	kind := resource.GetKind()
+	name := resource.GetName()
+	namespace := resource.GetNamespace()
 
 	key := fmt.Sprintf("%s/%s/%s/%s", repoState.name, namespace, kind, name)
 
@@ -382,6 +290,8 @@ func getResourceDescription(expected, actual *unstructured.Unstructured, mismatc
 		return fmt.Sprintf("%s/%s differs between Git and cluster", expected.GetKind(), expected.GetName())
 	case "extra":
 		return fmt.Sprintf("%s/%s exists in cluster but not in Git", actual.GetKind(), actual.GetName())
+	case "removed":
+		return fmt.Sprintf("%s/%s was removed from Git but still exists in cluster", actual.GetKind(), actual.GetName())
 	default:
 		return fmt.Sprintf("Unknown mismatch type: %s", mismatchType)
 	}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Drift alerts list at most this many changed paths, the attached diff has the rest
+const gitOpsDiffSummaryLines = 10
+
+// Discord rejects embed field values longer than this
+const alertFieldValueLimit = 1024
+
+// gitOpsFieldChange is a single path that differs between the cluster and Git
+type gitOpsFieldChange struct {
+	Path     string
+	Type     string // "changed", "added", "removed"
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// gitOpsDiff is the difference between a live resource and what applying Git would make of it
+type gitOpsDiff struct {
+	Changes []gitOpsFieldChange
+	Unified string
+}
+
+// Metadata the API server or controllers maintain, which never comes from Git
+var gitOpsVolatileMetadata = []string{
+	"managedFields",
+	"resourceVersion",
+	"uid",
+	"generation",
+	"creationTimestamp",
+	"selfLink",
+}
+
+// computeGitOpsDiff compares the live resource with the dry-run result of applying the Git manifest.
+// Like the comparison itself, it skips ignored fields and only covers the fields Git sets when managedFields are available.
+func computeGitOpsDiff(repositoryName string, desired, actual *unstructured.Unstructured) *gitOpsDiff {
+	if desired == nil || actual == nil {
+		return nil
+	}
+
+	desired = applyGitOpsIgnoreDifferences(repositoryName, desired)
+	actual = applyGitOpsIgnoreDifferences(repositoryName, actual)
+
+	if owned, ok := getGitOpsOwnedFields(desired); ok {
+		desired, actual = projectGitOpsObject(desired, owned), projectGitOpsObject(actual, owned)
+	}
+
+	desiredObject := cleanGitOpsDiffObject(desired)
+	actualObject := cleanGitOpsDiffObject(actual)
+
+	diff := &gitOpsDiff{}
+	collectGitOpsFieldChanges("", actualObject, desiredObject, &diff.Changes)
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		return diff.Changes[i].Path < diff.Changes[j].Path
+	})
+
+	actualYAML, err := yaml.Marshal(actualObject)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to marshal live resource for diff")
+		return diff
+	}
+	desiredYAML, err := yaml.Marshal(desiredObject)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to marshal desired resource for diff")
+		return diff
+	}
+
+	diff.Unified, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(actualYAML)),
+		B:        difflib.SplitLines(string(desiredYAML)),
+		FromFile: "cluster",
+		ToFile:   "git",
+		Context:  3,
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to compute unified diff")
+	}
+
+	return diff
+}
+
+// cleanGitOpsDiffObject copies a resource without status and volatile metadata.
+// Secret values are replaced by a short hash, so changed keys are still visible.
+func cleanGitOpsDiffObject(obj *unstructured.Unstructured) map[string]interface{} {
+	cleaned := obj.DeepCopy().Object
+
+	delete(cleaned, "status")
+	for _, field := range gitOpsVolatileMetadata {
+		unstructured.RemoveNestedField(cleaned, "metadata", field)
+	}
+
+	if obj.GetKind() == "Secret" {
+		// The last applied configuration holds the Secret in plain text
+		unstructured.RemoveNestedField(cleaned, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+		for _, field := range []string{"data", "stringData"} {
+			values, found, _ := unstructured.NestedMap(cleaned, field)
+			if !found {
+				continue
+			}
+			for key, value := range values {
+				values[key] = maskSecretValue(value)
+			}
+			_ = unstructured.SetNestedMap(cleaned, values, field)
+		}
+	}
+
+	return cleaned
+}
+
+// maskSecretValue replaces a Secret value with a hash prefix, enough to tell values apart but not to recover them
+func maskSecretValue(value interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+	return fmt.Sprintf("<masked sha256:%x>", sum[:4])
+}
+
+// collectGitOpsFieldChanges walks both objects and records every leaf that differs
+func collectGitOpsFieldChanges(path string, oldValue, newValue interface{}, changes *[]gitOpsFieldChange) {
+	oldMap, oldIsMap := oldValue.(map[string]interface{})
+	newMap, newIsMap := newValue.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		for key, oldChild := range oldMap {
+			childPath := joinGitOpsPath(path, key)
+			if newChild, exists := newMap[key]; exists {
+				collectGitOpsFieldChanges(childPath, oldChild, newChild, changes)
+			} else {
+				*changes = append(*changes, gitOpsFieldChange{Path: childPath, Type: "removed", OldValue: oldChild})
+			}
+		}
+		for key, newChild := range newMap {
+			if _, exists := oldMap[key]; !exists {
+				*changes = append(*changes, gitOpsFieldChange{Path: joinGitOpsPath(path, key), Type: "added", NewValue: newChild})
+			}
+		}
+		return
+	}
+
+	oldSlice, oldIsSlice := oldValue.([]interface{})
+	newSlice, newIsSlice := newValue.([]interface{})
+	if oldIsSlice && newIsSlice && len(oldSlice) == len(newSlice) {
+		for i := range oldSlice {
+			collectGitOpsFieldChanges(fmt.Sprintf("%s[%d]", path, i), oldSlice[i], newSlice[i], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldValue, newValue) {
+		*changes = append(*changes, gitOpsFieldChange{Path: path, Type: "changed", OldValue: oldValue, NewValue: newValue})
+	}
+}
+
+func joinGitOpsPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// summary renders the changed paths for an alert field, one per line
+func (d *gitOpsDiff) summary() string {
+	var lines []string
+	for i, change := range d.Changes {
+		if i == gitOpsDiffSummaryLines {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(d.Changes)-gitOpsDiffSummaryLines))
+			break
+		}
+
+		switch change.Type {
+		case "added":
+			lines = append(lines, fmt.Sprintf("+ %s: %s", change.Path, formatGitOpsValue(change.NewValue)))
+		case "removed":
+			lines = append(lines, fmt.Sprintf("- %s: %s", change.Path, formatGitOpsValue(change.OldValue)))
+		default:
+			lines = append(lines, fmt.Sprintf("~ %s: %s → %s", change.Path, formatGitOpsValue(change.OldValue), formatGitOpsValue(change.NewValue)))
+		}
+	}
+
+	// Drop lines until the escaped value fits in a field
+	value := escapeAlertFieldValue(strings.Join(lines, "\n"))
+	for len(value) > alertFieldValueLimit && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+		value = escapeAlertFieldValue(strings.Join(lines, "\n") + "\n…")
+	}
+	return value
+}
+
+// formatGitOpsValue renders a value compactly, shortening large objects
+func formatGitOpsValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if len(encoded) > 60 {
+		return string(encoded[:57]) + "..."
+	}
+	return string(encoded)
+}
+
+// escapeAlertFieldValue escapes text for the hand-built webhook JSON payload
+func escapeAlertFieldValue(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded[1 : len(encoded)-1])
+}
+
+// alertFieldValue escapes free text such as error messages for a field, truncating it to what Discord accepts
+func alertFieldValue(text string) string {
+	value := escapeAlertFieldValue(text)
+	for len(value) > alertFieldValueLimit {
+		runes := []rune(text)
+		text = string(runes[:len(runes)*alertFieldValueLimit/len(value)-1])
+		value = escapeAlertFieldValue(text + "…")
+	}
+	return value
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// isApplyRejection reports whether a dry-run error means the API server would reject the manifest,
+// as opposed to sun failing to reach it
+func isApplyRejection(err error) bool {
+	if errors.IsInvalid(err) || errors.IsBadRequest(err) || errors.IsConflict(err) {
+		return true
+	}
+	// A forbidden error is only about the manifest when an admission webhook denied it, not RBAC
+	return errors.IsForbidden(err) && strings.Contains(err.Error(), "admission webhook")
+}
+
+// processGitOpsRenderFailure handles a repository whose manifests failed to render
+func processGitOpsRenderFailure(repoState *gitOpsRepositoryState, renderErr error) {
+	key := repoState.name
+
+	log.Error().
+		Err(renderErr).
+		Str("repository", repoState.name).
+		Msg("GitOps render failed")
+
+	updateGitOpsRenderState(key, true, renderErr.Error(), repoState.name)
+
+	if shouldSendAlert("gitops_render", key) {
+		sendGitOpsRenderFailureAlert(repoState, renderErr)
+		markGitOpsRenderAlertSent(key)
+	}
+}
+
+// processGitOpsRenderSuccess clears a previous render failure of a repository
+func processGitOpsRenderSuccess(repoState *gitOpsRepositoryState) {
+	key := repoState.name
+
+	checkGitOpsRenderRecovery(key, repoState.name)
+	updateGitOpsRenderState(key, false, "", repoState.name)
+}
+
+// processGitOpsRejection handles a manifest the API server would reject on apply
+func processGitOpsRejection(repoState *gitOpsRepositoryState, manifest *unstructured.Unstructured, applyErr error) {
+	kind := manifest.GetKind()
+	name := manifest.GetName()
+	namespace := manifest.GetNamespace()
+
+	key := fmt.Sprintf("%s/%s/%s/%s", repoState.name, namespace, kind, name)
+
+	log.Error().
+		Err(applyErr).
+		Str("repository", repoState.name).
+		Str("kind", kind).
+		Str("name", name).
+		Str("namespace", namespace).
+		Msg("GitOps apply would be rejected")
+
+	updateGitOpsRejectionState(key, true, applyErr.Error(), repoState.name, kind, name, namespace)
+
+	if shouldSendAlert("gitops_rejection", key) {
+		sendGitOpsRejectionAlert(repoState.name, manifest, applyErr)
+		markGitOpsRejectionAlertSent(key)
+	}
+}
+
+// processGitOpsAccepted clears a previous rejection of a manifest
+func processGitOpsAccepted(repoState *gitOpsRepositoryState, manifest *unstructured.Unstructured) {
+	kind := manifest.GetKind()
+	name := manifest.GetName()
+	namespace := manifest.GetNamespace()
+
+	key := fmt.Sprintf("%s/%s/%s/%s", repoState.name, namespace, kind, name)
+
+	gitOpsRejectionStatesLock.RLock()
+	_, exists := gitOpsRejectionStates[key]
+	gitOpsRejectionStatesLock.RUnlock()
+	if !exists {
+		return
+	}
+
+	checkGitOpsRejectionRecovery(key, repoState.name, kind, name, namespace)
+
+	gitOpsRejectionStatesLock.Lock()
+	delete(gitOpsRejectionStates, key)
+	gitOpsRejectionStatesLock.Unlock()
+}
+
+// pruneGitOpsRejectionStates forgets rejections of resources a repository no longer renders
+func pruneGitOpsRejectionStates(repositoryName string, manifests []*unstructured.Unstructured) {
+	rendered := make(map[string]bool)
+	for _, manifest := range manifests {
+		rendered[gitOpsStateKey(repositoryName, manifest)] = true
+	}
+
+	gitOpsRejectionStatesLock.Lock()
+	defer gitOpsRejectionStatesLock.Unlock()
+
+	for key, state := range gitOpsRejectionStates {
+		if state.repositoryName == repositoryName && !rendered[key] {
+			delete(gitOpsRejectionStates, key)
+		}
+	}
+}
+
+func updateGitOpsRenderState(key string, hasError bool, errorMessage, repositoryName string) {
+	gitOpsRenderStatesLock.Lock()
+	defer gitOpsRenderStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := gitOpsRenderStates[key]
+
+	newState := gitOpsState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		repositoryName: repositoryName,
+		mismatchType:   "render_failed",
+	}
+
+	// A render error keeps its alert state while it persists, even if the message changes
+	if !exists || !prevState.hasError || !hasError {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		newState.firstError = prevState.firstError
+		newState.alertSent = prevState.alertSent
+	}
+
+	gitOpsRenderStates[key] = newState
+}
+
+func updateGitOpsRejectionState(key string, hasError bool, errorMessage, repositoryName, resourceKind, resourceName, namespace string) {
+	gitOpsRejectionStatesLock.Lock()
+	defer gitOpsRejectionStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := gitOpsRejectionStates[key]
+
+	newState := gitOpsState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		repositoryName: repositoryName,
+		resourceKind:   resourceKind,
+		resourceName:   resourceName,
+		namespace:      namespace,
+		mismatchType:   "rejected",
+	}
+
+	// If this is a new rejection or the reason has changed, reset the alert state
+	if !exists || (!prevState.hasError && hasError) || (prevState.hasError && prevState.lastMessage != errorMessage) {
+		newState.firstError = now
+		newState.alertSent = false
+	} else if prevState.hasError {
+		newState.firstError = prevState.firstError
+		newState.alertSent = prevState.alertSent
+	}
+
+	gitOpsRejectionStates[key] = newState
+}
+
+func markGitOpsRenderAlertSent(key string) {
+	gitOpsRenderStatesLock.Lock()
+	defer gitOpsRenderStatesLock.Unlock()
+
+	if state, exists := gitOpsRenderStates[key]; exists {
+		state.alertSent = true
+		gitOpsRenderStates[key] = state
+	}
+}
+
+func markGitOpsRejectionAlertSent(key string) {
+	gitOpsRejectionStatesLock.Lock()
+	defer gitOpsRejectionStatesLock.Unlock()
+
+	if state, exists := gitOpsRejectionStates[key]; exists {
+		state.alertSent = true
+		gitOpsRejectionStates[key] = state
+	}
+}
+
+// sendGitOpsRenderFailureAlert reports a Kustomize or Helm error that stops a repository from being compared
+func sendGitOpsRenderFailureAlert(repoState *gitOpsRepositoryState, renderErr error) {
+	alert := Alert{
+		Title:       fmt.Sprintf("GitOps Alert: Render Failed in %s", repoState.name),
+		Description: "Manifests could not be built, so the repository isn't compared with the cluster",
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Repository", Value: repoState.name, Inline: true},
+			{Name: "Path", Value: repoState.path, Inline: true},
+			{Name: "Error", Value: alertFieldValue(renderErr.Error()), Inline: false},
+			{Name: "Action Required", Value: "Fix the Kustomize or Helm configuration in the repository", Inline: false},
+		},
+	}
+
+	appendGitOpsCommitField(&alert, repoState.name)
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("repository", repoState.name).
+		Msg("GitOps render failure alert sent")
+}
+
+// sendGitOpsRejectionAlert reports a manifest the API server refuses, with its message
+func sendGitOpsRejectionAlert(repositoryName string, manifest *unstructured.Unstructured, applyErr error) {
+	alert := Alert{
+		Title:       fmt.Sprintf("GitOps Alert: Apply Would Be Rejected in %s", repositoryName),
+		Description: fmt.Sprintf("The API server rejects %s/%s as defined in Git", manifest.GetKind(), manifest.GetName()),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Repository", Value: repositoryName, Inline: true},
+			{Name: "Resource Kind", Value: manifest.GetKind(), Inline: true},
+			{Name: "Resource Name", Value: manifest.GetName(), Inline: true},
+		},
+	}
+
+	if namespace := manifest.GetNamespace(); namespace != "" {
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Namespace", Value: namespace, Inline: true})
+	}
+
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "Mismatch Type", Value: "rejected", Inline: true})
+
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "API Server Message", Value: alertFieldValue(applyErr.Error()), Inline: false})
+
+	alert.Fields = append(alert.Fields, struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{Name: "Action Required", Value: "Fix the manifest in Git, it can't be applied as is", Inline: false})
+
+	appendGitOpsSourceField(&alert, repositoryName, manifest)
+	appendGitOpsCommitField(&alert, repositoryName)
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("repository", repositoryName).
+		Str("kind", manifest.GetKind()).
+		Str("name", manifest.GetName()).
+		Msg("GitOps rejection alert sent")
+}
+
+// checkGitOpsRenderRecovery sends a recovery alert once a repository renders again
+func checkGitOpsRenderRecovery(key, repositoryName string) {
+	gitOpsRenderStatesLock.RLock()
+	prevState, exists := gitOpsRenderStates[key]
+	gitOpsRenderStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       fmt.Sprintf("GitOps Recovery: %s", repositoryName),
+			Description: "Manifests render again and are compared with the cluster",
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Repository", Value: repositoryName, Inline: true},
+				{Name: "Status", Value: "✅ In Sync", Inline: true},
+			},
+		}
+
+		appendGitOpsCommitField(&alert, repositoryName)
+
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("repository", repositoryName).
+			Msg("GitOps repository renders again")
+	}
+}
+
+// checkGitOpsRejectionRecovery sends a recovery alert once the API server accepts a manifest again
+func checkGitOpsRejectionRecovery(key, repositoryName, resourceKind, resourceName, namespace string) {
+	gitOpsRejectionStatesLock.RLock()
+	prevState, exists := gitOpsRejectionStates[key]
+	gitOpsRejectionStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       fmt.Sprintf("GitOps Recovery: %s", repositoryName),
+			Description: fmt.Sprintf("Resource %s/%s is accepted by the API server again", resourceKind, resourceName),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Repository", Value: repositoryName, Inline: true},
+				{Name: "Resource Kind", Value: resourceKind, Inline: true},
+				{Name: "Resource Name", Value: resourceName, Inline: true},
+			},
+		}
+
+		if namespace != "" {
+			alert.Fields = append(alert.Fields, struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{Name: "Namespace", Value: namespace, Inline: true})
+		}
+
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Status", Value: "✅ In Sync", Inline: true})
+
+		appendGitOpsCommitField(&alert, repositoryName)
+
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("repository", repositoryName).
+			Str("kind", resourceKind).
+			Str("name", resourceName).
+			Str("namespace", namespace).
+			Msg("GitOps resource is accepted again")
+	}
+}
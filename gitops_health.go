@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigMap in sun's namespace exposing the sync health of every repository
+const gitOpsStatusConfigMap = "sun-gitops-status"
+
+// Backoff between attempts of the initial clone
+const (
+	gitOpsInitialSyncBackoff    = 10 * time.Second
+	gitOpsInitialSyncBackoffMax = 10 * time.Minute
+)
+
+var (
+	// Set when the health of a repository changed since the status ConfigMap was written.
+	// Starts set so a new leader writes the status it has.
+	gitOpsStatusDirty     = true
+	gitOpsStatusDirtyLock sync.Mutex
+)
+
+// gitOpsRepositoryHealth is the sync health of a repository
+type gitOpsRepositoryHealth struct {
+	Healthy             bool      `json:"healthy"`
+	Commit              string    `json:"commit,omitempty"`
+	LastAttempt         time.Time `json:"lastAttempt"`
+	LastSuccessfulSync  time.Time `json:"lastSuccessfulSync"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastErrorTime       time.Time `json:"lastErrorTime"`
+	FailingSince        time.Time `json:"failingSince"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+}
+
+// syncRepositoryWithRetry keeps retrying the initial sync with exponential backoff until it succeeds or ctx ends.
+// Pushes cut the backoff short.
+func syncRepositoryWithRetry(ctx context.Context, repoState *gitOpsRepositoryState) bool {
+	backoff := gitOpsInitialSyncBackoff
+
+	for {
+		err := syncRepository(repoState)
+		recordGitOpsSyncResult(repoState, err)
+		if err == nil {
+			return true
+		}
+
+		log.Error().
+			Err(err).
+			Str("repository", repoState.name).
+			Dur("retryIn", backoff).
+			Msg("Failed initial repository sync, retrying")
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-repoState.syncRequests:
+			// A push means the repository changed, possibly fixing the failure, so retry right away
+			log.Debug().Str("repository", repoState.name).Msg("Push received, retrying initial repository sync")
+			continue
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > gitOpsInitialSyncBackoffMax {
+			backoff = gitOpsInitialSyncBackoffMax
+		}
+	}
+}
+
+// recordGitOpsSyncResult updates the health of a repository after a sync attempt and alerts on long failures
+func recordGitOpsSyncResult(repoState *gitOpsRepositoryState, syncErr error) {
+	now := time.Now()
+
+	repoState.mutex.Lock()
+	previous := repoState.health
+	health := previous
+	health.LastAttempt = now
+	if syncErr == nil {
+		health.Healthy = true
+		health.Commit = repoState.lastCommit
+		health.LastSuccessfulSync = now
+		health.FailingSince = time.Time{}
+		health.ConsecutiveFailures = 0
+	} else {
+		health.Healthy = false
+		health.LastError = syncErr.Error()
+		health.LastErrorTime = now
+		if health.ConsecutiveFailures == 0 {
+			health.FailingSince = now
+		}
+		health.ConsecutiveFailures++
+	}
+	repoState.health = health
+	repoState.mutex.Unlock()
+
+	// Attempt times change on every sync, they're only written along with a real change
+	if health.Healthy != previous.Healthy || health.Commit != previous.Commit ||
+		health.LastError != previous.LastError || health.ConsecutiveFailures != previous.ConsecutiveFailures {
+		markGitOpsStatusDirty()
+	}
+
+	processGitOpsSyncHealth(repoState.name, health)
+	saveGitOpsStatus()
+}
+
+// processGitOpsSyncHealth alerts once a repository has failed to sync for longer than the threshold
+func processGitOpsSyncHealth(repositoryName string, health gitOpsRepositoryHealth) {
+	key := repositoryName
+
+	threshold := time.Duration(config.GitOps.SyncFailureAlertMinutes) * time.Minute
+	hasError := !health.Healthy && time.Since(health.FailingSince) >= threshold
+
+	var errorMessage string
+	if hasError {
+		errorMessage = fmt.Sprintf("Repository failed to sync for %s", time.Since(health.FailingSince).Round(time.Minute))
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkGitOpsSyncRecovery(key, repositoryName)
+	}
+
+	updateGitOpsSyncState(key, hasError, errorMessage, repositoryName)
+
+	if hasError && shouldSendAlert("gitops_sync", key) {
+		sendGitOpsSyncFailureAlert(repositoryName, health)
+		markGitOpsSyncAlertSent(key)
+	}
+}
+
+func updateGitOpsSyncState(key string, hasError bool, errorMessage, repositoryName string) {
+	gitOpsSyncStatesLock.Lock()
+	defer gitOpsSyncStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := gitOpsSyncStates[key]
+
+	newState := gitOpsState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		repositoryName: repositoryName,
+		mismatchType:   "sync_failed",
+	}
+
+	// The message counts the failure duration, so only the error flag decides whether the alert state resets
+	if !exists || !prevState.hasError || !hasError {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		newState.firstError = prevState.firstError
+		newState.alertSent = prevState.alertSent
+	}
+
+	gitOpsSyncStates[key] = newState
+}
+
+func markGitOpsSyncAlertSent(key string) {
+	gitOpsSyncStatesLock.Lock()
+	defer gitOpsSyncStatesLock.Unlock()
+
+	if state, exists := gitOpsSyncStates[key]; exists {
+		state.alertSent = true
+		gitOpsSyncStates[key] = state
+	}
+}
+
+// formatGitOpsSyncTime renders a sync timestamp for an alert field
+func formatGitOpsSyncTime(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), time.Since(t).Round(time.Second))
+}
+
+func sendGitOpsSyncFailureAlert(repositoryName string, health gitOpsRepositoryHealth) {
+	alert := Alert{
+		Title:       fmt.Sprintf("GitOps Alert: Repository Sync Failing for %s", repositoryName),
+		Description: fmt.Sprintf("Repository has failed to sync for %s, the cluster is compared with stale manifests", time.Since(health.FailingSince).Round(time.Minute)),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Repository", Value: repositoryName, Inline: true},
+			{Name: "Failed Attempts", Value: fmt.Sprintf("%d", health.ConsecutiveFailures), Inline: true},
+			{Name: "Last Successful Sync", Value: formatGitOpsSyncTime(health.LastSuccessfulSync), Inline: false},
+			{Name: "Last Error", Value: alertFieldValue(health.LastError), Inline: false},
+			{Name: "Action Required", Value: "Check the repository URL, branch, credentials and network access", Inline: false},
+		},
+	}
+
+	// The cluster is still compared with the last commit that synced
+	appendGitOpsCommitField(&alert, repositoryName)
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("repository", repositoryName).
+		Int("failures", health.ConsecutiveFailures).
+		Msg("GitOps sync failure alert sent")
+}
+
+func checkGitOpsSyncRecovery(key, repositoryName string) {
+	gitOpsSyncStatesLock.RLock()
+	prevState, exists := gitOpsSyncStates[key]
+	gitOpsSyncStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       fmt.Sprintf("GitOps Recovery: %s", repositoryName),
+			Description: "Repository syncs again",
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Repository", Value: repositoryName, Inline: true},
+				{Name: "Status", Value: "✅ In Sync", Inline: true},
+			},
+		}
+
+		appendGitOpsCommitField(&alert, repositoryName)
+
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("repository", repositoryName).
+			Msg("GitOps repository syncs again")
+	}
+}
+
+// getGitOpsRepositoryHealth returns the sync health of every repository
+func getGitOpsRepositoryHealth() map[string]gitOpsRepositoryHealth {
+	gitOpsRepositoriesLock.RLock()
+	defer gitOpsRepositoriesLock.RUnlock()
+
+	health := make(map[string]gitOpsRepositoryHealth)
+	for name, repoState := range gitOpsRepositories {
+		repoState.mutex.RLock()
+		health[name] = repoState.health
+		repoState.mutex.RUnlock()
+	}
+	return health
+}
+
+// saveGitOpsStatus publishes repository health to the status ConfigMap, only the leader writes it
+func saveGitOpsStatus() {
+	leaderLock.RLock()
+	leader := isLeader
+	leaderLock.RUnlock()
+	if !leader {
+		return
+	}
+
+	gitOpsStatusDirtyLock.Lock()
+	if !gitOpsStatusDirty {
+		gitOpsStatusDirtyLock.Unlock()
+		return
+	}
+	// Cleared before writing, a failed write marks it again
+	gitOpsStatusDirty = false
+	gitOpsStatusDirtyLock.Unlock()
+
+	data := make(map[string]string)
+	for repositoryName, health := range getGitOpsRepositoryHealth() {
+		// The key is only a ConfigMap-safe form of the name, so the name is part of the value
+		encoded, err := json.Marshal(struct {
+			Repository string `json:"repository"`
+			gitOpsRepositoryHealth
+		}{Repository: repositoryName, gitOpsRepositoryHealth: health})
+		if err != nil {
+			log.Error().Err(err).Str("repository", repositoryName).Msg("Failed to encode GitOps repository status")
+			continue
+		}
+		data[gitOpsConfigMapKey(repositoryName)] = string(encoded)
+	}
+
+	namespace := detectNamespace()
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+
+	configMap, err := configMaps.Get(context.TODO(), gitOpsStatusConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      gitOpsStatusConfigMap,
+				Namespace: namespace,
+			},
+			Data: data,
+		}
+		if _, err := configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{}); err != nil {
+			log.Error().Err(err).Msg("Failed to create GitOps status ConfigMap")
+			markGitOpsStatusDirty()
+		}
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get GitOps status ConfigMap")
+		markGitOpsStatusDirty()
+		return
+	}
+
+	configMap.Data = data
+	if _, err := configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{}); err != nil {
+		log.Error().Err(err).Msg("Failed to update GitOps status ConfigMap")
+		markGitOpsStatusDirty()
+	}
+}
+
+func markGitOpsStatusDirty() {
+	gitOpsStatusDirtyLock.Lock()
+	gitOpsStatusDirty = true
+	gitOpsStatusDirtyLock.Unlock()
+}
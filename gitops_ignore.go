@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// gitOpsPathSegment is one step of a JSON pointer or JSONPath expression
+type gitOpsPathSegment struct {
+	key         string   // Map key, or list index when numeric
+	wildcard    bool     // Every map value or list element
+	filter      []string // Field path of list elements compared with filterValue
+	filterValue string
+}
+
+// Labels and annotations set by tooling rather than taken from Git, always ignored
+var gitOpsSystemIgnoreRules = []GitOpsIgnoreDifference{
+	{
+		JSONPointers: []string{
+			"/metadata/labels/app.kubernetes.io~1managed-by",
+			"/metadata/labels/helm.sh~1chart",
+			"/metadata/labels/app.kubernetes.io~1instance",
+			"/metadata/labels/app.kubernetes.io~1version",
+			"/metadata/annotations/kubectl.kubernetes.io~1last-applied-configuration",
+			"/metadata/annotations/deployment.kubernetes.io~1revision",
+			"/metadata/annotations/meta.helm.sh~1release-name",
+			"/metadata/annotations/meta.helm.sh~1release-namespace",
+		},
+	},
+}
+
+// getGitOpsIgnoreRules returns the system rules, then the global ignore rules, then the repository's own
+func getGitOpsIgnoreRules(repositoryName string) []GitOpsIgnoreDifference {
+	rules := append([]GitOpsIgnoreDifference{}, gitOpsSystemIgnoreRules...)
+	rules = append(rules, config.GitOps.IgnoreDifferences...)
+	for _, repo := range config.GitOps.Repositories {
+		if repo.Name == repositoryName {
+			rules = append(rules, repo.IgnoreDifferences...)
+		}
+	}
+	return rules
+}
+
+// matches reports whether a rule applies to a resource, empty fields match anything
+func (r GitOpsIgnoreDifference) matches(obj *unstructured.Unstructured) bool {
+	gv, _ := schema.ParseGroupVersion(obj.GetAPIVersion())
+	return (r.Group == "" || r.Group == gv.Group) &&
+		(r.Kind == "" || r.Kind == obj.GetKind()) &&
+		(r.Name == "" || r.Name == obj.GetName()) &&
+		(r.Namespace == "" || r.Namespace == obj.GetNamespace())
+}
+
+// applyGitOpsIgnoreDifferences returns a copy of a resource without the fields the repository's rules ignore
+func applyGitOpsIgnoreDifferences(repositoryName string, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj == nil {
+		return nil
+	}
+
+	result := obj.DeepCopy()
+	for _, rule := range getGitOpsIgnoreRules(repositoryName) {
+		if !rule.matches(obj) {
+			continue
+		}
+
+		for _, pointer := range rule.JSONPointers {
+			segments, err := parseJSONPointer(pointer)
+			if err != nil {
+				log.Warn().Err(err).Str("repository", repositoryName).Str("pointer", pointer).Msg("Invalid JSON pointer in ignore_differences")
+				continue
+			}
+			removeGitOpsPath(result.Object, segments)
+		}
+
+		for _, expression := range rule.JSONPathExpressions {
+			segments, err := parseJSONPath(expression)
+			if err != nil {
+				log.Warn().Err(err).Str("repository", repositoryName).Str("expression", expression).Msg("Invalid JSONPath expression in ignore_differences")
+				continue
+			}
+			removeGitOpsPath(result.Object, segments)
+		}
+	}
+
+	return result
+}
+
+// parseJSONPointer parses an RFC 6901 pointer such as /metadata/labels/app.kubernetes.io~1name
+func parseJSONPointer(pointer string) ([]gitOpsPathSegment, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer must start with /")
+	}
+
+	var segments []gitOpsPathSegment
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(token, "~1", "/")
+		token = strings.ReplaceAll(token, "~0", "~")
+		segments = append(segments, gitOpsPathSegment{key: token})
+	}
+	return segments, nil
+}
+
+// parseJSONPath parses the subset of JSONPath that selects fields to ignore:
+// .field, ['field'], [0], [*], .* and [?(@.field=="value")]
+func parseJSONPath(expression string) ([]gitOpsPathSegment, error) {
+	expression = strings.TrimSpace(expression)
+	expression = strings.TrimPrefix(expression, "{")
+	expression = strings.TrimSuffix(expression, "}")
+	expression = strings.TrimPrefix(expression, "$")
+
+	var segments []gitOpsPathSegment
+	for len(expression) > 0 {
+		switch expression[0] {
+		case '.':
+			expression = expression[1:]
+			end := strings.IndexAny(expression, ".[")
+			if end == -1 {
+				end = len(expression)
+			}
+			name := expression[:end]
+			if name == "" {
+				return nil, fmt.Errorf("empty field name")
+			}
+			if name == "*" {
+				segments = append(segments, gitOpsPathSegment{wildcard: true})
+			} else {
+				segments = append(segments, gitOpsPathSegment{key: name})
+			}
+			expression = expression[end:]
+
+		case '[':
+			end := strings.Index(expression, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated bracket")
+			}
+			// Quoted keys may contain ], find the closing quote first
+			if len(expression) > 1 && (expression[1] == '\'' || expression[1] == '"') {
+				closing := strings.IndexByte(expression[2:], expression[1])
+				if closing == -1 {
+					return nil, fmt.Errorf("unterminated quote")
+				}
+				end = 2 + closing + 1
+				if end >= len(expression) || expression[end] != ']' {
+					return nil, fmt.Errorf("expected ] after quoted key")
+				}
+			}
+
+			segment, err := parseJSONPathBracket(expression[1:end])
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+			expression = expression[end+1:]
+
+		default:
+			// A leading field without a dot, like spec.replicas
+			if len(segments) == 0 {
+				expression = "." + expression
+				continue
+			}
+			return nil, fmt.Errorf("unexpected %q", expression[0])
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	return segments, nil
+}
+
+func parseJSONPathBracket(content string) (gitOpsPathSegment, error) {
+	content = strings.TrimSpace(content)
+
+	switch {
+	case content == "*":
+		return gitOpsPathSegment{wildcard: true}, nil
+
+	case strings.HasPrefix(content, "'") || strings.HasPrefix(content, "\""):
+		return gitOpsPathSegment{key: content[1 : len(content)-1]}, nil
+
+	case strings.HasPrefix(content, "?(") && strings.HasSuffix(content, ")"):
+		condition := strings.TrimSpace(content[2 : len(content)-1])
+		field, value, found := strings.Cut(condition, "==")
+		if !found {
+			return gitOpsPathSegment{}, fmt.Errorf("only == filters are supported")
+		}
+		field = strings.TrimSpace(field)
+		if !strings.HasPrefix(field, "@.") {
+			return gitOpsPathSegment{}, fmt.Errorf("filter must compare a field of @")
+		}
+		value = strings.Trim(strings.TrimSpace(value), "'\"")
+		return gitOpsPathSegment{filter: strings.Split(field[2:], "."), filterValue: value}, nil
+
+	default:
+		if _, err := strconv.Atoi(content); err != nil {
+			return gitOpsPathSegment{}, fmt.Errorf("invalid index %q", content)
+		}
+		return gitOpsPathSegment{key: content}, nil
+	}
+}
+
+// removeGitOpsPath deletes everything a path selects and returns the updated value.
+// Maps are changed in place, lists are rebuilt.
+func removeGitOpsPath(value interface{}, segments []gitOpsPathSegment) interface{} {
+	if len(segments) == 0 {
+		return value
+	}
+	segment, rest := segments[0], segments[1:]
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if segment.filter != nil {
+			return v
+		}
+		for key, child := range v {
+			if !segment.wildcard && key != segment.key {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(v, key)
+			} else {
+				v[key] = removeGitOpsPath(child, rest)
+			}
+		}
+		return v
+
+	case []interface{}:
+		var kept []interface{}
+		for i, element := range v {
+			if !segment.selectsElement(i, element) {
+				kept = append(kept, element)
+				continue
+			}
+			if len(rest) > 0 {
+				kept = append(kept, removeGitOpsPath(element, rest))
+			}
+		}
+		return kept
+	}
+
+	return value
+}
+
+// selectsElement reports whether a segment selects a list element
+func (s gitOpsPathSegment) selectsElement(index int, element interface{}) bool {
+	if s.wildcard {
+		return true
+	}
+	if s.filter != nil {
+		elementMap, ok := element.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		value, found, _ := unstructured.NestedFieldNoCopy(elementMap, s.filter...)
+		return found && fmt.Sprint(value) == s.filterValue
+	}
+	selected, err := strconv.Atoi(s.key)
+	return err == nil && selected == index
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ConfigMap in sun's namespace that keeps every repository's inventory across restarts
+const gitOpsInventoryConfigMap = "sun-gitops-inventory"
+
+// gitOpsInventoryEntry is a resource that was rendered from a repository at some commit
+type gitOpsInventoryEntry struct {
+	APIVersion   string    `json:"apiVersion"`
+	Kind         string    `json:"kind"`
+	Namespace    string    `json:"namespace,omitempty"`
+	Name         string    `json:"name"`
+	Commit       string    `json:"commit"`
+	LastRendered time.Time `json:"lastRendered"`
+}
+
+// gitOpsInventoryRecord is the ConfigMap value of a repository's inventory.
+// The key is only a ConfigMap-safe form of the name, so the name is kept here.
+type gitOpsInventoryRecord struct {
+	Repository string                          `json:"repository"`
+	Entries    map[string]gitOpsInventoryEntry `json:"entries"`
+}
+
+func (e gitOpsInventoryEntry) id() string {
+	gv, _ := schema.ParseGroupVersion(e.APIVersion)
+	return fmt.Sprintf("%s/%s/%s/%s", gv.Group, e.Kind, e.Namespace, e.Name)
+}
+
+var (
+	// Every resource each repository has ever rendered, until it's gone from the cluster
+	gitOpsInventory     = make(map[string]map[string]gitOpsInventoryEntry)
+	gitOpsInventoryLock sync.RWMutex
+
+	// Set when the inventory has entries the ConfigMap doesn't, guarded by gitOpsInventoryLock.
+	// Starts set so a new leader writes what it loaded and rendered.
+	gitOpsInventoryDirty = true
+
+	// Resources each repository rendered at its current commit
+	gitOpsRendered     = make(map[string]map[string]bool)
+	gitOpsRenderedLock sync.RWMutex
+)
+
+// gitOpsResourceID identifies a resource across repositories and API versions
+func gitOpsResourceID(obj *unstructured.Unstructured) string {
+	gv, _ := schema.ParseGroupVersion(obj.GetAPIVersion())
+	return fmt.Sprintf("%s/%s/%s/%s", gv.Group, obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
+
+// gitOpsStateKey returns the GitOps state key of a resource in a repository
+func gitOpsStateKey(repositoryName string, obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s/%s", repositoryName, obj.GetNamespace(), obj.GetKind(), obj.GetName())
+}
+
+// isRenderedByAnyRepository reports whether any repository currently renders the resource
+func isRenderedByAnyRepository(id string) bool {
+	gitOpsRenderedLock.RLock()
+	defer gitOpsRenderedLock.RUnlock()
+
+	for _, rendered := range gitOpsRendered {
+		if rendered[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// allRepositoriesRendered reports whether every repository has rendered at least once.
+// Until then a resource owned by another repository would look extra.
+func allRepositoriesRendered() bool {
+	gitOpsRepositoriesLock.RLock()
+	defer gitOpsRepositoriesLock.RUnlock()
+	gitOpsRenderedLock.RLock()
+	defer gitOpsRenderedLock.RUnlock()
+
+	for name := range gitOpsRepositories {
+		if _, exists := gitOpsRendered[name]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// isGeneratedResource reports whether a live resource was created by Kubernetes or a controller rather than applied
+func isGeneratedResource(obj *unstructured.Unstructured) bool {
+	if len(obj.GetOwnerReferences()) > 0 {
+		return true
+	}
+
+	// The API server maintains its own Service and endpoints in the default namespace
+	if obj.GetNamespace() == "default" && obj.GetName() == "kubernetes" {
+		switch obj.GetKind() {
+		case "Service", "Endpoints", "EndpointSlice":
+			return true
+		}
+	}
+
+	switch obj.GetKind() {
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	case "ServiceAccount":
+		return obj.GetName() == "default"
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType == string(corev1.SecretTypeServiceAccountToken) || secretType == "helm.sh/release.v1"
+	}
+
+	return false
+}
+
+// detectExtraResources reports live resources that aren't rendered from Git.
+// Resources this repository rendered at an earlier commit are reported as removed from Git,
+// other resources of the tracked kinds in the tracked namespaces as extra.
+func detectExtraResources(repoState *gitOpsRepositoryState, manifests []*unstructured.Unstructured) error {
+	repoState.mutex.RLock()
+	commit := repoState.lastCommit
+	repoState.mutex.RUnlock()
+
+	rendered := make(map[string]bool)
+	trackedNamespaces := make(map[schema.GroupVersionKind]map[string]bool)
+	for _, manifest := range manifests {
+		rendered[gitOpsResourceID(manifest)] = true
+		// Namespaces were settled from the discovered scope during the comparison
+		if manifest.GetNamespace() == "" {
+			continue
+		}
+		gvk := manifest.GroupVersionKind()
+		if trackedNamespaces[gvk] == nil {
+			trackedNamespaces[gvk] = make(map[string]bool)
+		}
+		trackedNamespaces[gvk][manifest.GetNamespace()] = true
+	}
+
+	gitOpsRenderedLock.Lock()
+	gitOpsRendered[repoState.name] = rendered
+	gitOpsRenderedLock.Unlock()
+
+	updateGitOpsInventory(repoState.name, manifests, commit)
+
+	if !allRepositoriesRendered() {
+		log.Debug().Str("repository", repoState.name).Msg("Waiting for all repositories to render before detecting extra resources")
+		return nil
+	}
+
+	found := make(map[string]bool)
+
+	// Resources that were removed from Git but linger in the cluster
+	gitOpsInventoryLock.RLock()
+	var removed []gitOpsInventoryEntry
+	for id, entry := range gitOpsInventory[repoState.name] {
+		if !isRenderedByAnyRepository(id) {
+			removed = append(removed, entry)
+		}
+	}
+	gitOpsInventoryLock.RUnlock()
+
+	for _, entry := range removed {
+		reference := &unstructured.Unstructured{}
+		reference.SetAPIVersion(entry.APIVersion)
+		reference.SetKind(entry.Kind)
+		reference.SetNamespace(entry.Namespace)
+
+		resourceClient, err := getResourceClient(reference)
+		if meta.IsNoMatchError(err) {
+			// The kind itself is gone, so is the resource
+			removeFromGitOpsInventory(repoState.name, entry.id())
+			continue
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("kind", entry.Kind).Msg("Failed to resolve removed resource")
+			continue
+		}
+
+		live, err := resourceClient.Get(context.TODO(), entry.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			// Gone from the cluster as well, nothing left to track
+			removeFromGitOpsInventory(repoState.name, entry.id())
+			continue
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("kind", entry.Kind).Str("name", entry.Name).Msg("Failed to get removed resource from cluster")
+			continue
+		}
+
+		found[gitOpsStateKey(repoState.name, live)] = true
+		processGitOpsMismatch(repoState, nil, live, "removed")
+	}
+
+	// Resources of the tracked kinds in the tracked namespaces that no repository renders
+	for gvk, namespaces := range trackedNamespaces {
+		kind := gvk.Kind
+		mapping, err := getRESTMapping(gvk.GroupVersion().String(), kind)
+		if err != nil {
+			continue
+		}
+
+		for namespace := range namespaces {
+			list, err := dynamicClient.Resource(mapping.Resource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				log.Debug().Err(err).Str("kind", kind).Str("namespace", namespace).Msg("Failed to list resources for extra detection")
+				continue
+			}
+
+			for i := range list.Items {
+				live := &list.Items[i]
+				live.SetKind(kind)
+
+				key := gitOpsStateKey(repoState.name, live)
+				if found[key] || isRenderedByAnyRepository(gitOpsResourceID(live)) || isInAnyGitOpsInventory(gitOpsResourceID(live)) {
+					continue
+				}
+				if isGeneratedResource(live) || shouldFilterResource(live) {
+					continue
+				}
+
+				found[key] = true
+				processGitOpsMismatch(repoState, nil, live, "extra")
+			}
+		}
+	}
+
+	clearResolvedExtraResources(repoState.name, found)
+	saveGitOpsInventory()
+
+	return nil
+}
+
+// clearResolvedExtraResources sends recoveries for extra resources that were deleted or added to Git
+func clearResolvedExtraResources(repositoryName string, found map[string]bool) {
+	gitOpsStatesLock.RLock()
+	var resolved []string
+	for key, state := range gitOpsStates {
+		if state.repositoryName != repositoryName || found[key] {
+			continue
+		}
+		if state.mismatchType == "extra" || state.mismatchType == "removed" {
+			resolved = append(resolved, key)
+		}
+	}
+	gitOpsStatesLock.RUnlock()
+
+	for _, key := range resolved {
+		gitOpsStatesLock.RLock()
+		state := gitOpsStates[key]
+		gitOpsStatesLock.RUnlock()
+
+		checkGitOpsRecovery(key, repositoryName, state.resourceKind, state.resourceName, state.namespace)
+
+		gitOpsStatesLock.Lock()
+		delete(gitOpsStates, key)
+		gitOpsStatesLock.Unlock()
+	}
+}
+
+// updateGitOpsInventory records every rendered resource with the commit it was rendered at
+func updateGitOpsInventory(repositoryName string, manifests []*unstructured.Unstructured, commit string) {
+	gitOpsInventoryLock.Lock()
+	defer gitOpsInventoryLock.Unlock()
+
+	inventory, exists := gitOpsInventory[repositoryName]
+	if !exists {
+		inventory = make(map[string]gitOpsInventoryEntry)
+		gitOpsInventory[repositoryName] = inventory
+	}
+
+	now := time.Now()
+	for _, manifest := range manifests {
+		id := gitOpsResourceID(manifest)
+		// The render time alone doesn't warrant rewriting the ConfigMap
+		if previous, exists := inventory[id]; !exists || previous.Commit != commit {
+			gitOpsInventoryDirty = true
+		}
+		inventory[id] = gitOpsInventoryEntry{
+			APIVersion:   manifest.GetAPIVersion(),
+			Kind:         manifest.GetKind(),
+			Namespace:    manifest.GetNamespace(),
+			Name:         manifest.GetName(),
+			Commit:       commit,
+			LastRendered: now,
+		}
+	}
+}
+
+// isInAnyGitOpsInventory reports whether any repository ever rendered the resource
+func isInAnyGitOpsInventory(id string) bool {
+	gitOpsInventoryLock.RLock()
+	defer gitOpsInventoryLock.RUnlock()
+
+	for _, inventory := range gitOpsInventory {
+		if _, exists := inventory[id]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// getGitOpsInventoryEntry returns the inventory entry of a resource rendered from a repository
+func getGitOpsInventoryEntry(repositoryName string, obj *unstructured.Unstructured) (gitOpsInventoryEntry, bool) {
+	gitOpsInventoryLock.RLock()
+	defer gitOpsInventoryLock.RUnlock()
+
+	entry, exists := gitOpsInventory[repositoryName][gitOpsResourceID(obj)]
+	return entry, exists
+}
+
+func removeFromGitOpsInventory(repositoryName, id string) {
+	gitOpsInventoryLock.Lock()
+	defer gitOpsInventoryLock.Unlock()
+
+	if _, exists := gitOpsInventory[repositoryName][id]; exists {
+		delete(gitOpsInventory[repositoryName], id)
+		gitOpsInventoryDirty = true
+	}
+}
+
+// loadGitOpsInventory restores repository inventories from the ConfigMap
+func loadGitOpsInventory(ctx context.Context) error {
+	namespace := detectNamespace()
+	configMap, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, gitOpsInventoryConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s/%s: %w", namespace, gitOpsInventoryConfigMap, err)
+	}
+
+	gitOpsInventoryLock.Lock()
+	defer gitOpsInventoryLock.Unlock()
+
+	for key, data := range configMap.Data {
+		var record gitOpsInventoryRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil || record.Repository == "" {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to parse GitOps inventory, starting empty")
+			continue
+		}
+		if record.Entries == nil {
+			record.Entries = make(map[string]gitOpsInventoryEntry)
+		}
+		gitOpsInventory[record.Repository] = record.Entries
+	}
+
+	log.Info().Int("repositories", len(configMap.Data)).Msg("Loaded GitOps inventory")
+	return nil
+}
+
+// saveGitOpsInventory persists repository inventories to the ConfigMap, only the leader writes it
+func saveGitOpsInventory() {
+	leaderLock.RLock()
+	leader := isLeader
+	leaderLock.RUnlock()
+	if !leader {
+		return
+	}
+
+	gitOpsInventoryLock.Lock()
+	if !gitOpsInventoryDirty {
+		gitOpsInventoryLock.Unlock()
+		return
+	}
+	data := make(map[string]string)
+	for repositoryName, inventory := range gitOpsInventory {
+		encoded, err := json.Marshal(gitOpsInventoryRecord{Repository: repositoryName, Entries: inventory})
+		if err != nil {
+			log.Error().Err(err).Str("repository", repositoryName).Msg("Failed to encode GitOps inventory")
+			continue
+		}
+		data[gitOpsConfigMapKey(repositoryName)] = string(encoded)
+	}
+	// Cleared before writing, a failed write marks it again
+	gitOpsInventoryDirty = false
+	gitOpsInventoryLock.Unlock()
+
+	namespace := detectNamespace()
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+
+	configMap, err := configMaps.Get(context.TODO(), gitOpsInventoryConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      gitOpsInventoryConfigMap,
+				Namespace: namespace,
+			},
+			Data: data,
+		}
+		if _, err := configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{}); err != nil {
+			log.Error().Err(err).Msg("Failed to create GitOps inventory ConfigMap")
+			markGitOpsInventoryDirty()
+		}
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get GitOps inventory ConfigMap")
+		markGitOpsInventoryDirty()
+		return
+	}
+
+	configMap.Data = data
+	if _, err := configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{}); err != nil {
+		log.Error().Err(err).Msg("Failed to update GitOps inventory ConfigMap")
+		markGitOpsInventoryDirty()
+	}
+}
+
+func markGitOpsInventoryDirty() {
+	gitOpsInventoryLock.Lock()
+	gitOpsInventoryDirty = true
+	gitOpsInventoryLock.Unlock()
+}
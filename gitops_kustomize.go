@@ -101,16 +101,26 @@ func generateKustomizeManifests(repoState *gitOpsRepositoryState) ([]*unstructur
 		Reorder:           krusty.ReorderOptionUnspecified, // Let kustomization.yaml sortOptions take precedence
 	}
 
-	// Build the manifests
+	// Build the manifests, tracking which file each resource comes from
+	originFS := newOriginTrackingFS(fSys, kustomizePath)
 	k := krusty.MakeKustomizer(opts)
-	resMap, err := k.Run(fSys, kustomizePath)
+	resMap, err := k.Run(originFS, kustomizePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run kustomize for repository %s: %w", repoState.name, err)
 	}
 
 	// Convert to unstructured objects
 	var manifests []*unstructured.Unstructured
+	sources := make(map[string]string)
 	for _, res := range resMap.Resources() {
+		source := getResourceSource(res, kustomizePath, repoState.localPath)
+		if originFS.injected {
+			// The annotation was only added for sun, it isn't part of the desired state
+			if err := res.SetOrigin(nil); err != nil {
+				log.Debug().Err(err).Str("resource", res.CurId().String()).Msg("Failed to remove origin annotation")
+			}
+		}
+
 		// Get the resource as YAML
 		yamlBytes, err := res.AsYAML()
 		if err != nil {
@@ -148,6 +158,9 @@ func generateKustomizeManifests(repoState *gitOpsRepositoryState) ([]*unstructur
 		}
 
 		manifests = append(manifests, obj)
+		if source != "" {
+			sources[gitOpsStateKey(repoState.name, obj)] = source
+		}
 
 		log.Debug().
 			Str("repository", repoState.name).
@@ -157,6 +170,8 @@ func generateKustomizeManifests(repoState *gitOpsRepositoryState) ([]*unstructur
 			Msg("Generated manifest")
 	}
 
+	setGitOpsManifestSources(repoState.name, sources)
+
 	log.Info().
 		Str("repository", repoState.name).
 		Int("manifests", len(manifests)).
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Top-level content of objects without a spec, compared when managedFields aren't available
+var gitOpsContentFields = []string{
+	"spec",
+	"data",
+	"binaryData",
+	"stringData",
+	"rules",
+	"roleRef",
+	"subjects",
+	"webhooks",
+	"aggregationRule",
+}
+
+// getGitOpsOwnedFields returns the fields the Git manifest sets, from the managedFields entry of sun's apply
+func getGitOpsOwnedFields(obj *unstructured.Unstructured) (map[string]interface{}, bool) {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != gitOpsFieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			log.Debug().Err(err).Str("kind", obj.GetKind()).Str("name", obj.GetName()).Msg("Failed to parse managed fields")
+			return nil, false
+		}
+		return fields, true
+	}
+	return nil, false
+}
+
+// projectGitOpsObject keeps only the owned fields of a resource, plus what identifies it
+func projectGitOpsObject(obj *unstructured.Unstructured, owned map[string]interface{}) *unstructured.Unstructured {
+	projected, _ := projectOwnedFields(obj.Object, owned).(map[string]interface{})
+	if projected == nil {
+		projected = make(map[string]interface{})
+	}
+
+	result := &unstructured.Unstructured{Object: projected}
+	result.SetAPIVersion(obj.GetAPIVersion())
+	result.SetKind(obj.GetKind())
+	result.SetName(obj.GetName())
+	result.SetNamespace(obj.GetNamespace())
+
+	return result
+}
+
+// projectOwnedFields walks a value along a fieldsV1 set.
+// "f:" selects a map key, "k:" a list element by its key fields, "v:" a set element and "i:" a list index.
+// An empty set means the whole value is owned.
+func projectOwnedFields(value interface{}, fields map[string]interface{}) interface{} {
+	if len(fields) == 0 {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		projected := make(map[string]interface{})
+		for key, child := range fields {
+			if !strings.HasPrefix(key, "f:") {
+				continue
+			}
+			name := strings.TrimPrefix(key, "f:")
+			childValue, exists := v[name]
+			if !exists {
+				continue
+			}
+			childFields, _ := child.(map[string]interface{})
+			projected[name] = projectOwnedFields(childValue, childFields)
+		}
+		return projected
+
+	case []interface{}:
+		var projected []interface{}
+		for key, child := range fields {
+			childFields, _ := child.(map[string]interface{})
+
+			switch {
+			case strings.HasPrefix(key, "k:"):
+				var keyFields map[string]interface{}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &keyFields); err != nil {
+					continue
+				}
+				for _, element := range v {
+					if elementMatchesKey(element, keyFields) {
+						projected = append(projected, projectOwnedFields(element, childFields))
+						break
+					}
+				}
+
+			case strings.HasPrefix(key, "v:"):
+				var setValue interface{}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "v:")), &setValue); err != nil {
+					continue
+				}
+				for _, element := range v {
+					if jsonEqual(element, setValue) {
+						projected = append(projected, element)
+						break
+					}
+				}
+
+			case strings.HasPrefix(key, "i:"):
+				index, err := strconv.Atoi(strings.TrimPrefix(key, "i:"))
+				if err != nil || index < 0 || index >= len(v) {
+					continue
+				}
+				projected = append(projected, projectOwnedFields(v[index], childFields))
+			}
+		}
+
+		// The field set has no order, sort so both sides line up
+		sort.Slice(projected, func(i, j int) bool {
+			left, _ := json.Marshal(projected[i])
+			right, _ := json.Marshal(projected[j])
+			return string(left) < string(right)
+		})
+		return projected
+	}
+
+	return value
+}
+
+// elementMatchesKey reports whether a list element has all key fields of a "k:" entry
+func elementMatchesKey(element interface{}, keyFields map[string]interface{}) bool {
+	elementMap, ok := element.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for name, keyValue := range keyFields {
+		if !jsonEqual(elementMap[name], keyValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonEqual compares values by their JSON encoding, so integers match the floats JSON decoding produces
+func jsonEqual(a, b interface{}) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// Discovery is refreshed at most this often when a kind is unknown, so missing CRDs don't hammer the API server
+const restMapperMinRefreshInterval = 30 * time.Second
+
+var (
+	restMapper          *restmapper.DeferredDiscoveryRESTMapper
+	restMapperInit      sync.Once
+	restMapperLock      sync.Mutex
+	restMapperRefreshed time.Time
+)
+
+func getRESTMapper() *restmapper.DeferredDiscoveryRESTMapper {
+	restMapperInit.Do(func() {
+		restMapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client.Discovery()))
+		restMapperLock.Lock()
+		restMapperRefreshed = time.Now()
+		restMapperLock.Unlock()
+	})
+	return restMapper
+}
+
+// refreshRESTMapper drops cached discovery so CRDs installed since are found, unless that happened recently
+func refreshRESTMapper() bool {
+	restMapperLock.Lock()
+	defer restMapperLock.Unlock()
+
+	if time.Since(restMapperRefreshed) < restMapperMinRefreshInterval {
+		return false
+	}
+
+	log.Debug().Msg("Refreshing API discovery for unknown kind")
+	getRESTMapper().Reset()
+	restMapperRefreshed = time.Now()
+	return true
+}
+
+// getRESTMapping resolves the resource and scope of a kind in the exact group and version of a manifest
+func getRESTMapping(apiVersion, kind string) (*meta.RESTMapping, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion %q: %w", apiVersion, err)
+	}
+	groupKind := schema.GroupKind{Group: gv.Group, Kind: kind}
+
+	mapping, err := getRESTMapper().RESTMapping(groupKind, gv.Version)
+	if meta.IsNoMatchError(err) && refreshRESTMapper() {
+		mapping, err = getRESTMapper().RESTMapping(groupKind, gv.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s %s: %w", apiVersion, kind, err)
+	}
+
+	return mapping, nil
+}
+
+// getResourceClient returns a dynamic client for a resource with its discovered scope.
+// Like kubectl, namespaced resources without a namespace go to the default namespace,
+// and a namespace set on a cluster-scoped resource is dropped.
+func getResourceClient(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	mapping, err := getRESTMapping(obj.GetAPIVersion(), obj.GetKind())
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(metav1.NamespaceDefault)
+		}
+		return dynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+	}
+
+	obj.SetNamespace("")
+	return dynamicClient.Resource(mapping.Resource), nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	log "github.com/rs/zerolog/log"
@@ -118,6 +119,9 @@ func sendGitOpsMismatchAlert(repositoryName string, expected, actual *unstructur
 	case "extra":
 		title = fmt.Sprintf("GitOps Alert: Extra Resource in %s", repositoryName)
 		description = fmt.Sprintf("Resource %s/%s exists in cluster but not in Git", resourceKind, resourceName)
+	case "removed":
+		title = fmt.Sprintf("GitOps Alert: Resource Removed from Git in %s", repositoryName)
+		description = fmt.Sprintf("Resource %s/%s was removed from Git but still exists in cluster", resourceKind, resourceName)
 	default:
 		title = fmt.Sprintf("GitOps Alert: Unknown Issue in %s", repositoryName)
 		description = fmt.Sprintf("Unknown mismatch type %s for resource %s/%s", mismatchType, resourceKind, resourceName)
@@ -151,6 +155,11 @@ func sendGitOpsMismatchAlert(repositoryName string, expected, actual *unstructur
 		Inline bool
 	}{Name: "Mismatch Type", Value: mismatchType, Inline: true})
 
+	if mismatchType == "missing" || mismatchType == "different" {
+		appendGitOpsSourceField(&alert, repositoryName, expected)
+	}
+	appendGitOpsCommitField(&alert, repositoryName)
+
 	// Add additional context based on mismatch type
 	switch mismatchType {
 	case "missing":
@@ -165,12 +174,41 @@ func sendGitOpsMismatchAlert(repositoryName string, expected, actual *unstructur
 			Value  string
 			Inline bool
 		}{Name: "Action Required", Value: "Review differences and either update Git or apply changes to cluster", Inline: false})
+
+		if diff := computeGitOpsDiff(repositoryName, expected, actual); diff != nil {
+			if len(diff.Changes) > 0 {
+				alert.Fields = append(alert.Fields, struct {
+					Name   string
+					Value  string
+					Inline bool
+				}{Name: fmt.Sprintf("Changes (%d)", len(diff.Changes)), Value: diff.summary(), Inline: false})
+			}
+			if diff.Unified != "" {
+				alert.Attachments = append(alert.Attachments, AlertAttachment{
+					Filename: fmt.Sprintf("%s-%s.diff", strings.ToLower(resourceKind), resourceName),
+					Content:  []byte(diff.Unified),
+				})
+			}
+		}
 	case "extra":
 		alert.Fields = append(alert.Fields, struct {
 			Name   string
 			Value  string
 			Inline bool
 		}{Name: "Action Required", Value: "Remove resource from cluster or add to Git repository", Inline: false})
+	case "removed":
+		if entry, exists := getGitOpsInventoryEntry(repositoryName, actual); exists && entry.Commit != "" {
+			alert.Fields = append(alert.Fields, struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{Name: "Last Seen in Git", Value: fmt.Sprintf("%s (%s)", shortCommit(entry.Commit), entry.LastRendered.Format(time.RFC3339)), Inline: false})
+		}
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Action Required", Value: "Delete the resource from the cluster or restore it in Git", Inline: false})
 	}
 
 	sendWebhookMessage(alert)
@@ -218,6 +256,8 @@ func checkGitOpsRecovery(key, repositoryName, resourceKind, resourceName, namesp
 			Inline bool
 		}{Name: "Status", Value: "✅ In Sync", Inline: true})
 
+		appendGitOpsCommitField(&alert, repositoryName)
+
 		sendWebhookMessage(alert)
 		log.Info().
 			Str("repository", repositoryName).
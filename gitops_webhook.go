@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	log "github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Push payloads larger than this are refused
+const gitOpsWebhookMaxBody = 10 << 20
+
+// Set on requests a follower forwards, so they are never forwarded twice
+const gitOpsWebhookForwardedHeader = "X-Sun-Forwarded-By"
+
+// gitPushEvent holds the fields sun reads from GitHub, Gitea, Forgejo and GitLab push payloads
+type gitPushEvent struct {
+	Ref   string `json:"ref"`
+	After string `json:"after"`
+
+	// GitHub, Gitea and Forgejo
+	Repository struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+		HTMLURL  string `json:"html_url"`
+
+		// GitLab also sends a repository, with its own field names
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+	} `json:"repository"`
+
+	// GitLab
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		GitHTTPURL        string `json:"git_http_url"`
+		GitSSHURL         string `json:"git_ssh_url"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+}
+
+// urls returns every URL a push event names its repository by
+func (e *gitPushEvent) urls() []string {
+	var urls []string
+	for _, url := range []string{
+		e.Repository.CloneURL, e.Repository.SSHURL, e.Repository.HTMLURL,
+		e.Repository.GitHTTPURL, e.Repository.GitSSHURL,
+		e.Project.GitHTTPURL, e.Project.GitSSHURL, e.Project.WebURL,
+	} {
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// name returns the owner/name of the pushed repository, for logs
+func (e *gitPushEvent) name() string {
+	if e.Project.PathWithNamespace != "" {
+		return e.Project.PathWithNamespace
+	}
+	return e.Repository.FullName
+}
+
+// startGitOpsWebhookServer serves the push webhook endpoint until ctx ends
+func startGitOpsWebhookServer(ctx context.Context) {
+	path := config.GitOps.Webhook.Path
+	if path == "" {
+		path = "/webhooks/git"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handleGitOpsWebhook)
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", config.GitOps.Webhook.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Info().Str("address", server.Addr).Str("path", path).Msg("Starting GitOps webhook server")
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Error().Err(err).Msg("GitOps webhook server failed")
+	}
+}
+
+// handleGitOpsWebhook verifies a push event and queues an immediate sync of the matching repositories.
+// Followers hand the request to the leader, since only the leader syncs and alerts.
+func handleGitOpsWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, gitOpsWebhookMaxBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	leaderLock.RLock()
+	leader := isLeader
+	leaderLock.RUnlock()
+
+	if !leader {
+		forwardGitOpsWebhook(w, r, body)
+		return
+	}
+
+	provider, event := detectGitProvider(r)
+	if provider == "" {
+		http.Error(w, "unknown webhook provider", http.StatusBadRequest)
+		return
+	}
+	if event != "push" {
+		// Pings and other events are acknowledged so the Git host doesn't mark the hook as failing
+		log.Debug().Str("provider", provider).Str("event", event).Msg("Ignoring GitOps webhook event")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var push gitPushEvent
+	if err := json.Unmarshal(body, &push); err != nil {
+		http.Error(w, "invalid push payload", http.StatusBadRequest)
+		return
+	}
+
+	candidates := findGitOpsRepositoriesForPush(&push)
+	if len(candidates) == 0 {
+		log.Debug().Str("provider", provider).Str("repository", push.name()).Msg("Push webhook matches no GitOps repository")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	verified := 0
+	triggered := 0
+	for _, repoState := range candidates {
+		if err := verifyGitOpsWebhook(provider, r, body, repoState); err != nil {
+			log.Warn().
+				Err(err).
+				Str("provider", provider).
+				Str("repository", repoState.name).
+				Msg("Rejected GitOps webhook")
+			continue
+		}
+		verified++
+
+		if push.Ref != "refs/heads/"+repoState.branch {
+			continue
+		}
+
+		// A sync already waiting will pick up this push as well
+		select {
+		case repoState.syncRequests <- struct{}{}:
+		default:
+		}
+		triggered++
+
+		log.Info().
+			Str("provider", provider).
+			Str("repository", repoState.name).
+			Str("commit", shortCommit(push.After)).
+			Msg("Push webhook received, syncing repository")
+	}
+
+	if verified == 0 {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if triggered == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// detectGitProvider identifies the Git host from its headers and returns it with the event name
+func detectGitProvider(r *http.Request) (string, string) {
+	// Forgejo and Gitea also send the headers of their ancestors, check the most specific first
+	if event := r.Header.Get("X-Gitlab-Event"); event != "" {
+		if event == "Push Hook" {
+			event = "push"
+		}
+		return "gitlab", event
+	}
+	if event := r.Header.Get("X-Forgejo-Event"); event != "" {
+		return "forgejo", event
+	}
+	if event := r.Header.Get("X-Gitea-Event"); event != "" {
+		return "gitea", event
+	}
+	if event := r.Header.Get("X-GitHub-Event"); event != "" {
+		return "github", event
+	}
+	return "", ""
+}
+
+// verifyGitOpsWebhook checks a request against the webhook secret of a repository.
+// GitLab sends the secret as a token, the others sign the body with HMAC-SHA256.
+func verifyGitOpsWebhook(provider string, r *http.Request, body []byte, repoState *gitOpsRepositoryState) error {
+	secret, err := getGitOpsWebhookSecret(repoState)
+	if err != nil {
+		return err
+	}
+
+	if provider == "gitlab" {
+		token := r.Header.Get("X-Gitlab-Token")
+		if subtle.ConstantTimeCompare([]byte(token), secret) != 1 {
+			return fmt.Errorf("token mismatch")
+		}
+		return nil
+	}
+
+	var signature string
+	switch provider {
+	case "forgejo":
+		signature = r.Header.Get("X-Forgejo-Signature")
+	case "gitea":
+		signature = r.Header.Get("X-Gitea-Signature")
+	}
+	if signature == "" {
+		signature = strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	}
+	if signature == "" {
+		return fmt.Errorf("request is not signed")
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature")
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// getGitOpsWebhookSecret reads the webhook secret of a repository, so rotating it needs no restart
+func getGitOpsWebhookSecret(repoState *gitOpsRepositoryState) ([]byte, error) {
+	if repoState.webhook.SecretName == "" {
+		return nil, fmt.Errorf("no webhook secret configured")
+	}
+
+	namespace := detectNamespace()
+	secret, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), repoState.webhook.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Secret %s/%s: %w", namespace, repoState.webhook.SecretName, err)
+	}
+
+	value, err := getSecretValue(secret, repoState.webhook.SecretKey, "webhook-secret")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// findGitOpsRepositoriesForPush returns the configured repositories the push event is about
+func findGitOpsRepositoriesForPush(push *gitPushEvent) []*gitOpsRepositoryState {
+	pushed := make(map[string]bool)
+	for _, url := range push.urls() {
+		pushed[normalizeGitURL(url)] = true
+	}
+
+	gitOpsRepositoriesLock.RLock()
+	defer gitOpsRepositoriesLock.RUnlock()
+
+	var matches []*gitOpsRepositoryState
+	for _, repoState := range gitOpsRepositories {
+		if pushed[normalizeGitURL(repoState.url)] {
+			matches = append(matches, repoState)
+		}
+	}
+	return matches
+}
+
+// normalizeGitURL reduces HTTPS, SSH and scp-like URLs of a repository to host/owner/name
+func normalizeGitURL(url string) string {
+	host, path := "", url
+	if endpoint, err := transport.NewEndpoint(url); err == nil {
+		host, path = endpoint.Host, endpoint.Path
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	return strings.ToLower(host + "/" + path)
+}
+
+// forwardGitOpsWebhook passes a request received by a follower on to the leader pod
+func forwardGitOpsWebhook(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !config.GitOps.Webhook.ForwardToLeader {
+		http.Error(w, "not the leader", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get(gitOpsWebhookForwardedHeader) != "" {
+		// The leader changed while forwarding, let the Git host retry
+		http.Error(w, "not the leader", http.StatusServiceUnavailable)
+		return
+	}
+
+	leaderAddress, err := getLeaderAddress()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to find the leader for a GitOps webhook")
+		http.Error(w, "leader unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	target := fmt.Sprintf("http://%s%s", leaderAddress, r.URL.RequestURI())
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "failed to forward", http.StatusInternalServerError)
+		return
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Set(gitOpsWebhookForwardedHeader, os.Getenv("POD_NAME"))
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		log.Warn().Err(err).Str("leader", leaderAddress).Msg("Failed to forward GitOps webhook to the leader")
+		http.Error(w, "leader unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+
+	log.Debug().Str("leader", leaderAddress).Int("status", resp.StatusCode).Msg("Forwarded GitOps webhook to the leader")
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// getLeaderAddress returns the webhook address of the leader pod
+func getLeaderAddress() (string, error) {
+	leaderLock.RLock()
+	identity := leaderIdentity
+	leaderLock.RUnlock()
+
+	if identity == "" {
+		return "", fmt.Errorf("no leader elected")
+	}
+
+	pod, err := client.CoreV1().Pods(detectNamespace()).Get(context.TODO(), identity, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get leader pod %s: %w", identity, err)
+	}
+	if pod.Status.PodIP == "" {
+		return "", fmt.Errorf("leader pod %s has no IP", identity)
+	}
+
+	return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(config.GitOps.Webhook.Port)), nil
+}
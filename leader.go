@@ -56,6 +56,9 @@ func runLeaderElection(ctx context.Context) {
 				log.Info().Msg("Stopped leading")
 			},
 			OnNewLeader: func(identity string) {
+				leaderLock.Lock()
+				leaderIdentity = identity
+				leaderLock.Unlock()
 				log.Info().Str("leader", identity).Msg("New leader elected")
 			},
 		},
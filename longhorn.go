@@ -4,44 +4,173 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/tools/cache"
 )
 
-// Longhorn CRD GroupVersionResources
+// API group of all Longhorn CRDs
+const longhornGroup = "longhorn.io"
+
+// How often Longhorn CRDs are rediscovered while some monitored resources are still unavailable
+const longhornDiscoveryInterval = time.Minute
+
+// longhornResource describes a Longhorn CRD that sun can watch
+type longhornResource struct {
+	resource string // Plural resource name, e.g. "volumes"
+	enabled  func() bool
+	handlers cache.ResourceEventHandlerFuncs
+}
+
+// Discovered Longhorn GVRs and running informers, keyed by plural resource name
 var (
-	longhornVolumes = schema.GroupVersionResource{
-		Group:    "longhorn.io",
-		Version:  "v1beta2",
-		Resource: "volumes",
-	}
-	longhornReplicas = schema.GroupVersionResource{
-		Group:    "longhorn.io",
-		Version:  "v1beta2",
-		Resource: "replicas",
-	}
-	longhornEngines = schema.GroupVersionResource{
-		Group:    "longhorn.io",
-		Version:  "v1beta2",
-		Resource: "engines",
-	}
-	longhornNodes = schema.GroupVersionResource{
-		Group:    "longhorn.io",
-		Version:  "v1beta2",
-		Resource: "nodes",
-	}
-	longhornBackups = schema.GroupVersionResource{
-		Group:    "longhorn.io",
-		Version:  "v1beta2",
-		Resource: "backups",
-	}
+	longhornGVRs         = make(map[string]schema.GroupVersionResource)
+	longhornInformers    = make(map[string]cache.SharedIndexInformer)
+	longhornInformerLock sync.RWMutex
+
+	// Missing resources already reported, so the alert is only sent once
+	longhornMissingAlerted     = make(map[string]bool)
+	longhornMissingAlertedLock sync.Mutex
+
+	// The volume informer, replica and engine changes and the state ticker all evaluate volumes
+	longhornVolumeLocks sync.Map // namespace/name -> *sync.Mutex
 )
 
+// lockLonghornVolume holds a volume's lock across the alert check-and-mark, it returns the unlock function
+func lockLonghornVolume(key string) func() {
+	lock, _ := longhornVolumeLocks.LoadOrStore(key, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// longhornResources returns all Longhorn resources sun knows how to monitor
+func longhornResources() []longhornResource {
+	return []longhornResource{
+		{
+			resource: "volumes",
+			enabled:  func() bool { return config.Longhorn.Monitor.Volumes },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornVolume,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornVolume(obj) },
+				DeleteFunc: handleLonghornVolumeDelete,
+			},
+		},
+		{
+			resource: "replicas",
+			enabled:  func() bool { return config.Longhorn.Monitor.Replicas },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornReplica,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornReplica(obj) },
+				DeleteFunc: handleLonghornReplicaDelete,
+			},
+		},
+		{
+			resource: "engines",
+			enabled:  func() bool { return config.Longhorn.Monitor.Engines },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornEngine,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornEngine(obj) },
+				DeleteFunc: handleLonghornEngineDelete,
+			},
+		},
+		{
+			resource: "nodes",
+			enabled:  func() bool { return config.Longhorn.Monitor.Nodes },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornNode,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornNode(obj) },
+				DeleteFunc: handleLonghornNodeDelete,
+			},
+		},
+		{
+			resource: "backups",
+			enabled:  func() bool { return config.Longhorn.Monitor.Backups },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornBackup,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornBackup(obj) },
+				DeleteFunc: handleLonghornBackupDelete,
+			},
+		},
+		{
+			// BackupVolumes are only read from the cache for backup age checks
+			resource: "backupvolumes",
+			enabled:  func() bool { return config.Longhorn.Monitor.Backups },
+		},
+		{
+			resource: "recurringjobs",
+			enabled:  func() bool { return config.Longhorn.Monitor.RecurringJobs },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornRecurringJob,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornRecurringJob(obj) },
+				DeleteFunc: handleLonghornRecurringJobDelete,
+			},
+		},
+		{
+			resource: "backuptargets",
+			enabled:  func() bool { return config.Longhorn.Monitor.BackupTargets },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornBackupTarget,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornBackupTarget(obj) },
+				DeleteFunc: handleLonghornBackupTargetDelete,
+			},
+		},
+		{
+			resource: "snapshots",
+			enabled:  func() bool { return config.Longhorn.Monitor.Snapshots },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornSnapshot,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornSnapshot(obj) },
+				DeleteFunc: handleLonghornSnapshotDelete,
+			},
+		},
+		{
+			// Settings are always watched since disk and replication checks read them from the cache
+			resource: "settings",
+			enabled:  func() bool { return true },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornSetting,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornSetting(obj) },
+				DeleteFunc: handleLonghornSettingDelete,
+			},
+		},
+		{
+			resource: "engineimages",
+			enabled:  func() bool { return config.Longhorn.Monitor.EngineImages },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornEngineImage,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornEngineImage(obj) },
+				DeleteFunc: handleLonghornEngineImageDelete,
+			},
+		},
+		{
+			resource: "instancemanagers",
+			enabled:  func() bool { return config.Longhorn.Monitor.InstanceManagers },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornInstanceManager,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornInstanceManager(obj) },
+				DeleteFunc: handleLonghornInstanceManagerDelete,
+			},
+		},
+		{
+			resource: "sharemanagers",
+			enabled:  func() bool { return config.Longhorn.Monitor.ShareManagers },
+			handlers: cache.ResourceEventHandlerFuncs{
+				AddFunc:    handleLonghornShareManager,
+				UpdateFunc: func(_, obj interface{}) { handleLonghornShareManager(obj) },
+				DeleteFunc: handleLonghornShareManagerDelete,
+			},
+		},
+	}
+}
+
 // setupLonghornInformers sets up informers for Longhorn CRDs
 func setupLonghornInformers(ctx context.Context) error {
 	if !config.Longhorn.Enabled {
@@ -49,11 +178,7 @@ func setupLonghornInformers(ctx context.Context) error {
 		return nil
 	}
 
-	// Set default namespace if not specified
-	longhornNamespace := config.Longhorn.Namespace
-	if longhornNamespace == "" {
-		longhornNamespace = "longhorn-system"
-	}
+	longhornNamespace := getLonghornNamespace()
 
 	log.Info().Str("namespace", longhornNamespace).Msg("Setting up Longhorn monitoring")
 
@@ -65,68 +190,265 @@ func setupLonghornInformers(ctx context.Context) error {
 		nil,
 	)
 
-	// Setup Volume informer
-	if config.Longhorn.Monitor.Volumes {
-		volumeInformer := factory.ForResource(longhornVolumes).Informer()
-		volumeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc:    handleLonghornVolume,
-			UpdateFunc: func(_, obj interface{}) { handleLonghornVolume(obj) },
-			DeleteFunc: handleLonghornVolumeDelete,
-		})
-		log.Debug().Msg("Longhorn Volume informer configured")
-	}
-
-	// Setup Replica informer
-	if config.Longhorn.Monitor.Replicas {
-		replicaInformer := factory.ForResource(longhornReplicas).Informer()
-		replicaInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc:    handleLonghornReplica,
-			UpdateFunc: func(_, obj interface{}) { handleLonghornReplica(obj) },
-			DeleteFunc: handleLonghornReplicaDelete,
-		})
-		log.Debug().Msg("Longhorn Replica informer configured")
-	}
-
-	// Setup Engine informer
-	if config.Longhorn.Monitor.Engines {
-		engineInformer := factory.ForResource(longhornEngines).Informer()
-		engineInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc:    handleLonghornEngine,
-			UpdateFunc: func(_, obj interface{}) { handleLonghornEngine(obj) },
-			DeleteFunc: handleLonghornEngineDelete,
-		})
-		log.Debug().Msg("Longhorn Engine informer configured")
-	}
-
-	// Setup Node informer
-	if config.Longhorn.Monitor.Nodes {
-		nodeInformer := factory.ForResource(longhornNodes).Informer()
-		nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc:    handleLonghornNode,
-			UpdateFunc: func(_, obj interface{}) { handleLonghornNode(obj) },
-			DeleteFunc: handleLonghornNodeDelete,
-		})
-		log.Debug().Msg("Longhorn Node informer configured")
-	}
-
-	// Setup Backup informer
-	if config.Longhorn.Monitor.Backups {
-		backupInformer := factory.ForResource(longhornBackups).Informer()
-		backupInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc:    handleLonghornBackup,
-			UpdateFunc: func(_, obj interface{}) { handleLonghornBackup(obj) },
-			DeleteFunc: handleLonghornBackupDelete,
-		})
-		log.Debug().Msg("Longhorn Backup informer configured")
-	}
-
-	// Start informers
-	go factory.Start(ctx.Done())
-
-	log.Info().Msg("Longhorn informers started")
+	// Backup age and RecurringJob windows have to be checked on a timer, not on events
+	go runLonghornBackupChecks(ctx)
+	go runLonghornSnapshotChecks(ctx)
+	go runLonghornVolumeStateChecks(ctx)
+	go runLonghornEngineImageChecks(ctx)
+
+	// Start informers for everything that is already installed
+	if startLonghornInformers(ctx, factory) {
+		log.Info().Msg("Longhorn informers started")
+		return nil
+	}
+
+	// Keep rediscovering so CRDs installed later are picked up
+	go func() {
+		ticker := time.NewTicker(longhornDiscoveryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if startLonghornInformers(ctx, factory) {
+					log.Info().Msg("All monitored Longhorn resources are now available")
+					return
+				}
+			}
+		}
+	}()
+
+	log.Warn().Msg("Some monitored Longhorn resources are unavailable, will keep checking")
 	return nil
 }
 
+// startLonghornInformers discovers Longhorn CRDs and starts informers for enabled resources that
+// are not running yet. It returns true once every enabled resource has an informer.
+func startLonghornInformers(ctx context.Context, factory dynamicinformer.DynamicSharedInformerFactory) bool {
+	available, installed, err := discoverLonghornResources()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to discover Longhorn resources")
+		return false
+	}
+
+	if !installed {
+		log.Error().Str("group", longhornGroup).Msg("Longhorn is enabled but its CRDs are not installed")
+		reportLonghornUnavailable("", "Longhorn is enabled but no longhorn.io CRDs are installed in the cluster")
+	}
+
+	var missing, started []string
+	longhornInformerLock.Lock()
+	for resource, gvr := range available {
+		longhornGVRs[resource] = gvr
+	}
+	for _, res := range longhornResources() {
+		if !res.enabled() {
+			continue
+		}
+		if _, running := longhornInformers[res.resource]; running {
+			continue
+		}
+
+		gvr, ok := available[res.resource]
+		if !ok {
+			missing = append(missing, res.resource)
+			continue
+		}
+
+		informer := factory.ForResource(gvr).Informer()
+		// Volume checks look up their replicas, engines and snapshots on every event, index them by volume
+		indexFunc := longhornVolumeNameIndexFunc
+		if res.resource == "snapshots" {
+			indexFunc = longhornSnapshotVolumeIndexFunc
+		}
+		if res.resource == "replicas" || res.resource == "engines" || res.resource == "snapshots" {
+			if err := informer.AddIndexers(cache.Indexers{longhornVolumeNameIndex: indexFunc}); err != nil {
+				log.Warn().Err(err).Str("resource", res.resource).Msg("Failed to add Longhorn volume index")
+			}
+		}
+		informer.AddEventHandler(res.handlers)
+		longhornInformers[res.resource] = informer
+
+		log.Debug().
+			Str("resource", res.resource).
+			Str("version", gvr.Version).
+			Msg("Longhorn informer configured")
+
+		started = append(started, res.resource)
+	}
+	longhornInformerLock.Unlock()
+
+	for _, resource := range started {
+		clearLonghornUnavailable(resource)
+	}
+
+	if installed {
+		for _, resource := range missing {
+			log.Error().
+				Str("resource", resource+"."+longhornGroup).
+				Msg("Monitored Longhorn resource type is not available")
+			reportLonghornUnavailable(resource, fmt.Sprintf("%s.%s is not served by the API server", resource, longhornGroup))
+		}
+	}
+
+	// Start only starts informers that are not running yet
+	factory.Start(ctx.Done())
+
+	return len(missing) == 0
+}
+
+// discoverLonghornResources returns the preferred GVR for every Longhorn resource the API server serves
+func discoverLonghornResources() (map[string]schema.GroupVersionResource, bool, error) {
+	available := make(map[string]schema.GroupVersionResource)
+
+	groups, err := client.Discovery().ServerGroups()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get server groups: %w", err)
+	}
+
+	var group *metav1.APIGroup
+	for i := range groups.Groups {
+		if groups.Groups[i].Name == longhornGroup {
+			group = &groups.Groups[i]
+			break
+		}
+	}
+	if group == nil {
+		return available, false, nil
+	}
+
+	// Check the preferred version first so it wins for resources served in several versions
+	versions := []metav1.GroupVersionForDiscovery{group.PreferredVersion}
+	for _, version := range group.Versions {
+		if version.Version != group.PreferredVersion.Version {
+			versions = append(versions, version)
+		}
+	}
+
+	for _, version := range versions {
+		resourceList, err := client.Discovery().ServerResourcesForGroupVersion(version.GroupVersion)
+		if err != nil {
+			log.Warn().Err(err).Str("groupVersion", version.GroupVersion).Msg("Failed to get Longhorn resources")
+			continue
+		}
+
+		for _, apiResource := range resourceList.APIResources {
+			// Skip subresources (they contain '/')
+			if strings.Contains(apiResource.Name, "/") {
+				continue
+			}
+			if _, exists := available[apiResource.Name]; exists {
+				continue
+			}
+			available[apiResource.Name] = schema.GroupVersionResource{
+				Group:    longhornGroup,
+				Version:  version.Version,
+				Resource: apiResource.Name,
+			}
+		}
+	}
+
+	log.Debug().
+		Str("preferredVersion", group.PreferredVersion.Version).
+		Int("resources", len(available)).
+		Msg("Discovered Longhorn resources")
+
+	return available, true, nil
+}
+
+// getLonghornNamespace returns the configured Longhorn namespace
+func getLonghornNamespace() string {
+	// Set default namespace if not specified
+	if config.Longhorn.Namespace == "" {
+		return "longhorn-system"
+	}
+	return config.Longhorn.Namespace
+}
+
+// getLonghornGVR returns the discovered GroupVersionResource for a Longhorn resource, if served
+func getLonghornGVR(resource string) (schema.GroupVersionResource, bool) {
+	longhornInformerLock.RLock()
+	defer longhornInformerLock.RUnlock()
+
+	gvr, exists := longhornGVRs[resource]
+	return gvr, exists
+}
+
+// getLonghornSetting returns the value of a settings.longhorn.io object, preferring the informer cache
+func getLonghornSetting(name string) (string, bool) {
+	if settingInformer, ok := getLonghornInformer("settings"); ok && settingInformer.HasSynced() {
+		obj, exists, err := settingInformer.GetStore().GetByKey(getLonghornNamespace() + "/" + name)
+		if err == nil && exists {
+			if setting, ok := obj.(*unstructured.Unstructured); ok {
+				value, found, _ := unstructured.NestedString(setting.Object, "value")
+				return value, found
+			}
+		}
+	}
+
+	gvr, ok := getLonghornGVR("settings")
+	if !ok {
+		return "", false
+	}
+
+	setting, err := dynamicClient.Resource(gvr).Namespace(getLonghornNamespace()).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		log.Debug().Err(err).Str("setting", name).Msg("Failed to get Longhorn setting")
+		return "", false
+	}
+
+	value, found, _ := unstructured.NestedString(setting.Object, "value")
+	return value, found
+}
+
+// getLonghornInformer returns the running informer for a Longhorn resource, if any
+func getLonghornInformer(resource string) (cache.SharedIndexInformer, bool) {
+	longhornInformerLock.RLock()
+	defer longhornInformerLock.RUnlock()
+
+	informer, exists := longhornInformers[resource]
+	return informer, exists
+}
+
+// reportLonghornUnavailable sends a one-time alert for a missing Longhorn installation ("") or resource type
+func reportLonghornUnavailable(resource, message string) {
+	longhornMissingAlertedLock.Lock()
+	defer longhornMissingAlertedLock.Unlock()
+
+	if longhornMissingAlerted[resource] {
+		return
+	}
+
+	// Followers drop webhook messages, so wait until we lead before marking the alert as sent
+	leaderLock.RLock()
+	leader := isLeader
+	leaderLock.RUnlock()
+	if !leader {
+		return
+	}
+
+	sendLonghornUnavailableAlert(resource, message)
+	longhornMissingAlerted[resource] = true
+}
+
+// clearLonghornUnavailable notes that a previously missing Longhorn resource is now being monitored
+func clearLonghornUnavailable(resource string) {
+	longhornMissingAlertedLock.Lock()
+	defer longhornMissingAlertedLock.Unlock()
+
+	if !longhornMissingAlerted[resource] && !longhornMissingAlerted[""] {
+		return
+	}
+
+	delete(longhornMissingAlerted, resource)
+	delete(longhornMissingAlerted, "")
+
+	log.Info().Str("resource", resource+"."+longhornGroup).Msg("Longhorn resource type is now available")
+	sendLonghornAvailableAlert(resource)
+}
+
 // Volume handlers
 func handleLonghornVolume(obj interface{}) {
 	unstructuredObj, ok := obj.(*unstructured.Unstructured)
@@ -138,6 +460,9 @@ func handleLonghornVolume(obj interface{}) {
 	name := unstructuredObj.GetName()
 	namespace := unstructuredObj.GetNamespace()
 
+	unlock := lockLonghornVolume(fmt.Sprintf("%s/%s", namespace, name))
+	defer unlock()
+
 	log.Debug().
 		Str("volume", name).
 		Str("namespace", namespace).
@@ -166,7 +491,14 @@ func handleLonghornVolume(obj interface{}) {
 
 	actualSize, _, _ := unstructured.NestedInt64(status, "actualSize")
 
-	processLonghornVolumeStatus(name, namespace, state, robustness, capacity, actualSize)
+	// Replicas of a detached volume are stopped, so only attached volumes can be checked
+	var replication *longhornReplicationStatus
+	if state == "attached" {
+		numberOfReplicas, _, _ := unstructured.NestedInt64(spec, "numberOfReplicas")
+		replication = getLonghornReplicationStatus(name, numberOfReplicas)
+	}
+
+	processLonghornVolumeStatus(name, namespace, state, robustness, capacity, actualSize, replication, getLonghornKubernetesStatus(unstructuredObj))
 }
 
 func handleLonghornVolumeDelete(obj interface{}) {
@@ -179,6 +511,12 @@ func handleLonghornVolumeDelete(obj interface{}) {
 	longhornVolumeStatesLock.Lock()
 	delete(longhornVolumeStates, key)
 	longhornVolumeStatesLock.Unlock()
+
+	longhornSnapshotStatesLock.Lock()
+	delete(longhornSnapshotStates, key)
+	longhornSnapshotStatesLock.Unlock()
+
+	longhornVolumeLocks.Delete(key)
 }
 
 // Replica handlers
@@ -205,7 +543,14 @@ func handleLonghornReplica(obj interface{}) {
 
 	currentState, _, _ := unstructured.NestedString(status, "currentState")
 
-	processLonghornReplicaStatus(name, namespace, currentState)
+	// Alerts carry the PVC and workloads of the volume this replica belongs to
+	volumeName, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "volumeName")
+	kubeStatus := getLonghornVolumeKubernetesStatus(namespace, volumeName)
+
+	processLonghornReplicaStatus(name, namespace, currentState, volumeName, kubeStatus)
+
+	// Replica health feeds into the volume's replication checks
+	reevaluateLonghornVolume(namespace, volumeName)
 }
 
 func handleLonghornReplicaDelete(obj interface{}) {
@@ -218,6 +563,9 @@ func handleLonghornReplicaDelete(obj interface{}) {
 	longhornReplicaStatesLock.Lock()
 	delete(longhornReplicaStates, key)
 	longhornReplicaStatesLock.Unlock()
+
+	volumeName, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "volumeName")
+	reevaluateLonghornVolume(unstructuredObj.GetNamespace(), volumeName)
 }
 
 // Engine handlers
@@ -244,7 +592,14 @@ func handleLonghornEngine(obj interface{}) {
 
 	currentState, _, _ := unstructured.NestedString(status, "currentState")
 
-	processLonghornEngineStatus(name, namespace, currentState)
+	// Alerts carry the PVC and workloads of the volume this engine belongs to
+	volumeName, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "volumeName")
+	kubeStatus := getLonghornVolumeKubernetesStatus(namespace, volumeName)
+
+	processLonghornEngineStatus(name, namespace, currentState, volumeName, kubeStatus)
+
+	// Rebuild progress lives on the engine but is reported per volume
+	reevaluateLonghornVolume(namespace, volumeName)
 }
 
 func handleLonghornEngineDelete(obj interface{}) {
@@ -281,11 +636,16 @@ func handleLonghornNode(obj interface{}) {
 
 	// Check node conditions
 	conditions, found, err := unstructured.NestedSlice(status, "conditions")
-	if err != nil || !found {
-		return
+	if err == nil && found {
+		processLonghornNodeStatus(name, conditions)
 	}
 
-	processLonghornNodeStatus(name, conditions)
+	// Check per-disk capacity and schedulability
+	diskStatus, found, err := unstructured.NestedMap(status, "diskStatus")
+	if err == nil && found {
+		disks, _, _ := unstructured.NestedMap(unstructuredObj.Object, "spec", "disks")
+		processLonghornDiskStatus(name, disks, diskStatus)
+	}
 }
 
 func handleLonghornNodeDelete(obj interface{}) {
@@ -298,6 +658,8 @@ func handleLonghornNodeDelete(obj interface{}) {
 	longhornNodeStatesLock.Lock()
 	delete(longhornNodeStates, key)
 	longhornNodeStatesLock.Unlock()
+
+	cleanupLonghornDiskStates(key, nil)
 }
 
 // Backup handlers
@@ -323,8 +685,9 @@ func handleLonghornBackup(obj interface{}) {
 	}
 
 	state, _, _ := unstructured.NestedString(status, "state")
+	backupTarget := unstructuredObj.GetLabels()[longhornBackupTargetLabel]
 
-	processLonghornBackupStatus(name, namespace, state)
+	processLonghornBackupStatus(name, namespace, state, backupTarget)
 }
 
 func handleLonghornBackupDelete(obj interface{}) {
@@ -380,6 +743,42 @@ func shouldSendLonghornAlert(resourceType string, key string) bool {
 		longhornBackupStatesLock.RLock()
 		state, exists = longhornBackupStates[key]
 		longhornBackupStatesLock.RUnlock()
+	case "disk":
+		longhornDiskStatesLock.RLock()
+		state, exists = longhornDiskStates[key]
+		longhornDiskStatesLock.RUnlock()
+	case "backup_age":
+		longhornBackupAgeStatesLock.RLock()
+		state, exists = longhornBackupAgeStates[key]
+		longhornBackupAgeStatesLock.RUnlock()
+	case "recurring_job":
+		longhornRecurringJobStatesLock.RLock()
+		state, exists = longhornRecurringJobStates[key]
+		longhornRecurringJobStatesLock.RUnlock()
+	case "backup_target":
+		longhornBackupTargetStatesLock.RLock()
+		state, exists = longhornBackupTargetStates[key]
+		longhornBackupTargetStatesLock.RUnlock()
+	case "instance_manager":
+		longhornInstanceManagerStatesLock.RLock()
+		state, exists = longhornInstanceManagerStates[key]
+		longhornInstanceManagerStatesLock.RUnlock()
+	case "share_manager":
+		longhornShareManagerStatesLock.RLock()
+		state, exists = longhornShareManagerStates[key]
+		longhornShareManagerStatesLock.RUnlock()
+	case "snapshot":
+		longhornSnapshotStatesLock.RLock()
+		state, exists = longhornSnapshotStates[key]
+		longhornSnapshotStatesLock.RUnlock()
+	case "setting":
+		longhornSettingStatesLock.RLock()
+		state, exists = longhornSettingStates[key]
+		longhornSettingStatesLock.RUnlock()
+	case "engine_image":
+		longhornEngineImageStatesLock.RLock()
+		state, exists = longhornEngineImageStates[key]
+		longhornEngineImageStatesLock.RUnlock()
 	}
 
 	if !exists || !state.hasError || state.alertSent {
@@ -434,5 +833,68 @@ func markLonghornAlertSent(resourceType string, key string) {
 			state.alertSent = true
 			longhornBackupStates[key] = state
 		}
+	case "disk":
+		longhornDiskStatesLock.Lock()
+		defer longhornDiskStatesLock.Unlock()
+		if state, exists := longhornDiskStates[key]; exists {
+			state.alertSent = true
+			longhornDiskStates[key] = state
+		}
+	case "backup_age":
+		longhornBackupAgeStatesLock.Lock()
+		defer longhornBackupAgeStatesLock.Unlock()
+		if state, exists := longhornBackupAgeStates[key]; exists {
+			state.alertSent = true
+			longhornBackupAgeStates[key] = state
+		}
+	case "recurring_job":
+		longhornRecurringJobStatesLock.Lock()
+		defer longhornRecurringJobStatesLock.Unlock()
+		if state, exists := longhornRecurringJobStates[key]; exists {
+			state.alertSent = true
+			longhornRecurringJobStates[key] = state
+		}
+	case "backup_target":
+		longhornBackupTargetStatesLock.Lock()
+		defer longhornBackupTargetStatesLock.Unlock()
+		if state, exists := longhornBackupTargetStates[key]; exists {
+			state.alertSent = true
+			longhornBackupTargetStates[key] = state
+		}
+	case "instance_manager":
+		longhornInstanceManagerStatesLock.Lock()
+		defer longhornInstanceManagerStatesLock.Unlock()
+		if state, exists := longhornInstanceManagerStates[key]; exists {
+			state.alertSent = true
+			longhornInstanceManagerStates[key] = state
+		}
+	case "share_manager":
+		longhornShareManagerStatesLock.Lock()
+		defer longhornShareManagerStatesLock.Unlock()
+		if state, exists := longhornShareManagerStates[key]; exists {
+			state.alertSent = true
+			longhornShareManagerStates[key] = state
+		}
+	case "snapshot":
+		longhornSnapshotStatesLock.Lock()
+		defer longhornSnapshotStatesLock.Unlock()
+		if state, exists := longhornSnapshotStates[key]; exists {
+			state.alertSent = true
+			longhornSnapshotStates[key] = state
+		}
+	case "setting":
+		longhornSettingStatesLock.Lock()
+		defer longhornSettingStatesLock.Unlock()
+		if state, exists := longhornSettingStates[key]; exists {
+			state.alertSent = true
+			longhornSettingStates[key] = state
+		}
+	case "engine_image":
+		longhornEngineImageStatesLock.Lock()
+		defer longhornEngineImageStatesLock.Unlock()
+		if state, exists := longhornEngineImageStates[key]; exists {
+			state.alertSent = true
+			longhornEngineImageStates[key] = state
+		}
 	}
 }
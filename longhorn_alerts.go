@@ -2,12 +2,14 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	log "github.com/rs/zerolog/log"
 )
 
 // sendLonghornVolumeAlert sends an alert for a Longhorn volume issue
-func sendLonghornVolumeAlert(name, namespace, state, robustness string, capacity, actualSize int64, errorMessage, alertType string) {
+func sendLonghornVolumeAlert(name, namespace, state, robustness string, capacity, actualSize int64, errorMessage, alertType string, replication *longhornReplicationStatus, kubeStatus *longhornKubernetesStatus) {
 	// Calculate usage percentage for display
 	usagePercent := float64(0)
 	if capacity > 0 && actualSize > 0 {
@@ -59,6 +61,33 @@ func sendLonghornVolumeAlert(name, namespace, state, robustness string, capacity
 		})
 	}
 
+	// Add replica information if available
+	if replication != nil {
+		alert.Fields = append(alert.Fields, longhornReplicationFields(replication)...)
+	}
+
+	// Snapshots are the usual reason actualSize grows past what the filesystem uses
+	if alertType == "usage_critical" || alertType == "capacity_critical" {
+		if snapshots := getLonghornSnapshotSummary(name); snapshots != nil && snapshots.count > 0 {
+			alert.Fields = append(alert.Fields, struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				Name:   "Snapshots",
+				Value:  fmt.Sprintf("%d using %s", snapshots.count, formatLonghornBytes(snapshots.totalSize)),
+				Inline: true,
+			})
+		}
+	}
+
+	// Add the PVC and workloads using the volume
+	alert.Fields = append(alert.Fields, longhornKubernetesFields("", kubeStatus)...)
+
+	if !routeLonghornAlert(&alert, kubeStatus) {
+		return
+	}
+
 	sendWebhookMessage(alert)
 	log.Error().
 		Str("volume", name).
@@ -69,8 +98,78 @@ func sendLonghornVolumeAlert(name, namespace, state, robustness string, capacity
 		Msg("Longhorn volume alert sent")
 }
 
+// longhornReplicationFields returns alert fields describing replica health, placement and rebuilds
+func longhornReplicationFields(replication *longhornReplicationStatus) []struct {
+	Name   string
+	Value  string
+	Inline bool
+} {
+	fields := []struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{
+		{Name: "Healthy Replicas", Value: fmt.Sprintf("%d/%d", replication.healthy, replication.desired), Inline: true},
+	}
+
+	if len(replication.colocatedNodes) > 0 {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Co-located Nodes", Value: strings.Join(replication.colocatedNodes, ", "), Inline: true})
+	}
+
+	if len(replication.colocatedZones) > 0 {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Co-located Zones", Value: strings.Join(replication.colocatedZones, ", "), Inline: true})
+	}
+
+	if len(replication.rebuilds) > 0 {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Rebuild Progress", Value: formatLonghornRebuilds(replication.rebuilds), Inline: false})
+	}
+
+	return fields
+}
+
+// sendLonghornRebuildProgressAlert sends a progress update for a volume that is rebuilding replicas
+func sendLonghornRebuildProgressAlert(name, namespace string, replication *longhornReplicationStatus, kubeStatus *longhornKubernetesStatus) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Volume Rebuild on %s", namespace),
+		Description: fmt.Sprintf("Volume %s is rebuilding replicas: %d%% complete", name, replication.rebuildProgress()),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Volume", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+		},
+	}
+	alert.Fields = append(alert.Fields, longhornReplicationFields(replication)...)
+	alert.Fields = append(alert.Fields, longhornKubernetesFields("", kubeStatus)...)
+
+	if !routeLonghornAlert(&alert, kubeStatus) {
+		return
+	}
+
+	sendWebhookMessage(alert)
+	log.Info().
+		Str("volume", name).
+		Str("namespace", namespace).
+		Int64("progress", replication.rebuildProgress()).
+		Msg("Longhorn volume rebuild progress sent")
+}
+
 // sendLonghornReplicaAlert sends an alert for a Longhorn replica issue
-func sendLonghornReplicaAlert(name, namespace, currentState, errorMessage string) {
+func sendLonghornReplicaAlert(name, namespace, currentState, errorMessage, volumeName string, kubeStatus *longhornKubernetesStatus) {
 	alert := Alert{
 		Title:       fmt.Sprintf("Longhorn Replica Alert on %s", namespace),
 		Description: fmt.Sprintf("Replica %s: %s", name, errorMessage),
@@ -84,6 +183,11 @@ func sendLonghornReplicaAlert(name, namespace, currentState, errorMessage string
 			{Name: "State", Value: currentState, Inline: true},
 		},
 	}
+	alert.Fields = append(alert.Fields, longhornKubernetesFields(volumeName, kubeStatus)...)
+
+	if !routeLonghornAlert(&alert, kubeStatus) {
+		return
+	}
 
 	sendWebhookMessage(alert)
 	log.Error().
@@ -94,7 +198,7 @@ func sendLonghornReplicaAlert(name, namespace, currentState, errorMessage string
 }
 
 // sendLonghornEngineAlert sends an alert for a Longhorn engine issue
-func sendLonghornEngineAlert(name, namespace, currentState, errorMessage string) {
+func sendLonghornEngineAlert(name, namespace, currentState, errorMessage, volumeName string, kubeStatus *longhornKubernetesStatus) {
 	alert := Alert{
 		Title:       fmt.Sprintf("Longhorn Engine Alert on %s", namespace),
 		Description: fmt.Sprintf("Engine %s: %s", name, errorMessage),
@@ -108,6 +212,11 @@ func sendLonghornEngineAlert(name, namespace, currentState, errorMessage string)
 			{Name: "State", Value: currentState, Inline: true},
 		},
 	}
+	alert.Fields = append(alert.Fields, longhornKubernetesFields(volumeName, kubeStatus)...)
+
+	if !routeLonghornAlert(&alert, kubeStatus) {
+		return
+	}
 
 	sendWebhookMessage(alert)
 	log.Error().
@@ -117,6 +226,189 @@ func sendLonghornEngineAlert(name, namespace, currentState, errorMessage string)
 		Msg("Longhorn engine alert sent")
 }
 
+// sendLonghornInstanceManagerAlert sends an alert for a Longhorn instance manager issue
+func sendLonghornInstanceManagerAlert(name, namespace, currentState, nodeID, managerType string, instances int, errorMessage string) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Instance Manager Alert on %s", namespace),
+		Description: fmt.Sprintf("Instance manager %s: %s", name, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Instance Manager", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "State", Value: currentState, Inline: true},
+			{Name: "Node", Value: nodeID, Inline: true},
+			{Name: "Type", Value: managerType, Inline: true},
+			{Name: "Instances", Value: fmt.Sprintf("%d", instances), Inline: true},
+			{Name: "Impact", Value: "Engines and replicas on this node can't be started or served", Inline: false},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("instanceManager", name).
+		Str("namespace", namespace).
+		Str("node", nodeID).
+		Str("state", currentState).
+		Msg("Longhorn instance manager alert sent")
+}
+
+// sendLonghornShareManagerAlert sends an alert for a Longhorn share manager issue
+func sendLonghornShareManagerAlert(name, namespace, state, endpoint, errorMessage string, kubeStatus *longhornKubernetesStatus) {
+	if endpoint == "" {
+		endpoint = "None"
+	}
+
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Share Manager Alert on %s", namespace),
+		Description: fmt.Sprintf("Share manager %s: %s", name, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Share Manager", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "State", Value: state, Inline: true},
+			{Name: "Endpoint", Value: endpoint, Inline: false},
+		},
+	}
+	alert.Fields = append(alert.Fields, longhornKubernetesFields(name, kubeStatus)...)
+
+	if !routeLonghornAlert(&alert, kubeStatus) {
+		return
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("shareManager", name).
+		Str("namespace", namespace).
+		Str("state", state).
+		Msg("Longhorn share manager alert sent")
+}
+
+// sendLonghornSnapshotAlert sends an alert for a volume with too many or too large snapshots
+func sendLonghornSnapshotAlert(name, namespace, errorMessage, alertType string, capacity int64, summary *longhornSnapshotSummary, cleanupJobs []string, kubeStatus *longhornKubernetesStatus) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Snapshot Alert on %s", namespace),
+		Description: fmt.Sprintf("Volume %s: %s", name, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Volume", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "Alert Type", Value: alertType, Inline: true},
+			{Name: "Snapshots", Value: fmt.Sprintf("%d", summary.count), Inline: true},
+			{Name: "Total Snapshot Size", Value: formatLonghornBytes(summary.totalSize), Inline: true},
+			{Name: "Capacity", Value: formatLonghornBytes(capacity), Inline: true},
+			{Name: "Oldest Snapshot", Value: formatLonghornSnapshot(summary.oldest), Inline: false},
+			{Name: "Largest Snapshot", Value: formatLonghornSnapshot(summary.largest), Inline: false},
+			{Name: "Cleanup Jobs", Value: formatLonghornSnapshotCleanupJobs(cleanupJobs), Inline: false},
+		},
+	}
+	alert.Fields = append(alert.Fields, longhornKubernetesFields("", kubeStatus)...)
+
+	if !routeLonghornAlert(&alert, kubeStatus) {
+		return
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("volume", name).
+		Str("namespace", namespace).
+		Int("snapshots", summary.count).
+		Str("alertType", alertType).
+		Msg("Longhorn snapshot alert sent")
+}
+
+// sendLonghornSettingAlert sends an alert for a Longhorn setting that drifted from its expected value
+func sendLonghornSettingAlert(name, namespace, expected, actual, errorMessage string) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Setting Alert on %s", namespace),
+		Description: fmt.Sprintf("Setting %s: %s", name, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Setting", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "Expected", Value: expected, Inline: true},
+			{Name: "Actual", Value: actual, Inline: true},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("setting", name).
+		Str("namespace", namespace).
+		Str("expected", expected).
+		Str("actual", actual).
+		Msg("Longhorn setting alert sent")
+}
+
+// sendLonghornEngineImageAlert sends an alert for an engine image that isn't deployed everywhere
+func sendLonghornEngineImageAlert(name, namespace, image, state string, refCount int64, notDeployed []string, errorMessage string) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Engine Image Alert on %s", namespace),
+		Description: fmt.Sprintf("Engine image %s: %s", name, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Engine Image", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "State", Value: state, Inline: true},
+			{Name: "Image", Value: image, Inline: false},
+			{Name: "Volumes Using It", Value: fmt.Sprintf("%d", refCount), Inline: true},
+		},
+	}
+
+	if len(notDeployed) > 0 {
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Not Deployed On", Value: strings.Join(notDeployed, ", "), Inline: false})
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("engineImage", name).
+		Str("namespace", namespace).
+		Str("state", state).
+		Msg("Longhorn engine image alert sent")
+}
+
+// sendLonghornOutdatedVolumesAlert sends an alert for volumes still running an older engine image after an upgrade
+func sendLonghornOutdatedVolumesAlert(namespace, defaultImage string, upgradedAt time.Time, volumes []string, errorMessage string) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Engine Image Alert on %s", namespace),
+		Description: errorMessage,
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Default Image", Value: defaultImage, Inline: false},
+			{Name: "Upgraded", Value: upgradedAt.Format(time.RFC3339), Inline: true},
+			{Name: "Grace Period", Value: fmt.Sprintf("%dh", config.Longhorn.AlertThresholds.EngineImageGraceHours), Inline: true},
+			{Name: "Outdated Volumes", Value: formatLonghornVolumeList(volumes), Inline: false},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("namespace", namespace).
+		Int("volumes", len(volumes)).
+		Msg("Longhorn outdated engine image alert sent")
+}
+
 // sendLonghornNodeAlert sends an alert for a Longhorn node issue
 func sendLonghornNodeAlert(name, errorMessage string, conditions []interface{}) {
 	alert := Alert{
@@ -191,10 +483,159 @@ func sendLonghornBackupAlert(name, namespace, state, errorMessage string) {
 		Msg("Longhorn backup alert sent")
 }
 
+// sendLonghornDiskAlert sends an alert for a Longhorn disk issue
+func sendLonghornDiskAlert(info longhornDiskInfo, errorMessage, alertType string) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Disk Alert on %s", info.node),
+		Description: fmt.Sprintf("Disk %s on node %s: %s", info.path, info.node, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Node", Value: info.node, Inline: true},
+			{Name: "Disk", Value: info.disk, Inline: true},
+			{Name: "Path", Value: info.path, Inline: true},
+			{Name: "Alert Type", Value: alertType, Inline: true},
+			{Name: "Available", Value: formatLonghornBytes(info.storageAvailable), Inline: true},
+			{Name: "Maximum", Value: formatLonghornBytes(info.storageMaximum), Inline: true},
+			{Name: "Scheduled", Value: formatLonghornBytes(info.storageScheduled), Inline: true},
+			{Name: "Reserved", Value: formatLonghornBytes(info.storageReserved), Inline: true},
+			{Name: "Over-provisioning", Value: fmt.Sprintf("%d%%", info.overProvisioning), Inline: true},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("node", info.node).
+		Str("disk", info.disk).
+		Str("path", info.path).
+		Str("alertType", alertType).
+		Msg("Longhorn disk alert sent")
+}
+
+// formatLonghornBytes formats a byte count in GB for display
+func formatLonghornBytes(bytes int64) string {
+	return fmt.Sprintf("%.2f GB", float64(bytes)/(1024*1024*1024))
+}
+
+// sendLonghornBackupAgeAlert sends an alert for a volume whose last completed backup is too old
+func sendLonghornBackupAgeAlert(name, namespace, errorMessage string, lastBackup time.Time, maxAge time.Duration) {
+	lastBackupValue := "Never"
+	if !lastBackup.IsZero() {
+		lastBackupValue = lastBackup.Format(time.RFC3339)
+	}
+
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Stale Backup on %s", namespace),
+		Description: fmt.Sprintf("Volume %s: %s", name, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Volume", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "Last Backup", Value: lastBackupValue, Inline: true},
+			{Name: "Max Age", Value: maxAge.String(), Inline: true},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("volume", name).
+		Str("namespace", namespace).
+		Str("lastBackup", lastBackupValue).
+		Msg("Longhorn stale backup alert sent")
+}
+
+// sendLonghornRecurringJobAlert sends an alert for a backup RecurringJob that missed its window
+func sendLonghornRecurringJobAlert(name, namespace, cronSpec string, expectedRun time.Time, missing []string) {
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Recurring Job Alert on %s", namespace),
+		Description: fmt.Sprintf("Recurring job %s produced no backup for its run at %s", name, expectedRun.Format(time.RFC3339)),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Recurring Job", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "Schedule", Value: cronSpec, Inline: true},
+			{Name: "Volumes Without Backup", Value: strings.Join(missing, "\\n"), Inline: false},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("recurringJob", name).
+		Str("namespace", namespace).
+		Time("expectedRun", expectedRun).
+		Strs("volumes", missing).
+		Msg("Longhorn recurring job alert sent")
+}
+
+// sendLonghornBackupTargetAlert sends an alert for an unavailable Longhorn backup target
+func sendLonghornBackupTargetAlert(name, namespace, url, errorMessage, lastSyncedAt string) {
+	if lastSyncedAt == "" {
+		lastSyncedAt = "Never"
+	}
+
+	alert := Alert{
+		Title:       fmt.Sprintf("Longhorn Backup Target Alert on %s", namespace),
+		Description: fmt.Sprintf("Backup target %s: %s", name, errorMessage),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Backup Target", Value: name, Inline: true},
+			{Name: "Namespace", Value: namespace, Inline: true},
+			{Name: "URL", Value: url, Inline: false},
+			{Name: "Last Synced", Value: lastSyncedAt, Inline: true},
+			{Name: "Impact", Value: "Backups to this target will fail until it is reachable again", Inline: false},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("backupTarget", name).
+		Str("namespace", namespace).
+		Str("error", errorMessage).
+		Msg("Longhorn backup target alert sent")
+}
+
+// sendLonghornUnavailableAlert sends an alert when Longhorn or one of its monitored resource types is missing
+func sendLonghornUnavailableAlert(resource, message string) {
+	resourceName := "All"
+	if resource != "" {
+		resourceName = resource + "." + longhornGroup
+	}
+
+	alert := Alert{
+		Title:       "Longhorn Monitoring Unavailable",
+		Description: message,
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Resource", Value: resourceName, Inline: true},
+			{Name: "Namespace", Value: config.Longhorn.Namespace, Inline: true},
+			{Name: "Action Required", Value: "Install Longhorn or disable the resource under longhorn.monitor", Inline: false},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("resource", resourceName).
+		Msg("Longhorn unavailable alert sent")
+}
+
 // Recovery functions
 
 // checkLonghornVolumeRecovery checks if a volume has recovered and sends a recovery alert
-func checkLonghornVolumeRecovery(key, name, namespace string) {
+func checkLonghornVolumeRecovery(key, name, namespace string, kubeStatus *longhornKubernetesStatus) {
 	longhornVolumeStatesLock.RLock()
 	prevState, exists := longhornVolumeStates[key]
 	longhornVolumeStatesLock.RUnlock()
@@ -213,6 +654,12 @@ func checkLonghornVolumeRecovery(key, name, namespace string) {
 				{Name: "State", Value: "Healthy", Inline: true},
 			},
 		}
+		alert.Fields = append(alert.Fields, longhornKubernetesFields("", kubeStatus)...)
+
+		if !routeLonghornAlert(&alert, kubeStatus) {
+			return
+		}
+
 		sendWebhookMessage(alert)
 		log.Info().
 			Str("volume", name).
@@ -222,7 +669,7 @@ func checkLonghornVolumeRecovery(key, name, namespace string) {
 }
 
 // checkLonghornReplicaRecovery checks if a replica has recovered and sends a recovery alert
-func checkLonghornReplicaRecovery(key, name, namespace string) {
+func checkLonghornReplicaRecovery(key, name, namespace, volumeName string, kubeStatus *longhornKubernetesStatus) {
 	longhornReplicaStatesLock.RLock()
 	prevState, exists := longhornReplicaStates[key]
 	longhornReplicaStatesLock.RUnlock()
@@ -241,6 +688,12 @@ func checkLonghornReplicaRecovery(key, name, namespace string) {
 				{Name: "State", Value: "Running", Inline: true},
 			},
 		}
+		alert.Fields = append(alert.Fields, longhornKubernetesFields(volumeName, kubeStatus)...)
+
+		if !routeLonghornAlert(&alert, kubeStatus) {
+			return
+		}
+
 		sendWebhookMessage(alert)
 		log.Info().
 			Str("replica", name).
@@ -250,7 +703,7 @@ func checkLonghornReplicaRecovery(key, name, namespace string) {
 }
 
 // checkLonghornEngineRecovery checks if an engine has recovered and sends a recovery alert
-func checkLonghornEngineRecovery(key, name, namespace string) {
+func checkLonghornEngineRecovery(key, name, namespace, volumeName string, kubeStatus *longhornKubernetesStatus) {
 	longhornEngineStatesLock.RLock()
 	prevState, exists := longhornEngineStates[key]
 	longhornEngineStatesLock.RUnlock()
@@ -269,6 +722,12 @@ func checkLonghornEngineRecovery(key, name, namespace string) {
 				{Name: "State", Value: "Running", Inline: true},
 			},
 		}
+		alert.Fields = append(alert.Fields, longhornKubernetesFields(volumeName, kubeStatus)...)
+
+		if !routeLonghornAlert(&alert, kubeStatus) {
+			return
+		}
+
 		sendWebhookMessage(alert)
 		log.Info().
 			Str("engine", name).
@@ -277,6 +736,164 @@ func checkLonghornEngineRecovery(key, name, namespace string) {
 	}
 }
 
+// checkLonghornInstanceManagerRecovery checks if an instance manager has recovered and sends a recovery alert
+func checkLonghornInstanceManagerRecovery(key, name, namespace, nodeID string) {
+	longhornInstanceManagerStatesLock.RLock()
+	prevState, exists := longhornInstanceManagerStates[key]
+	longhornInstanceManagerStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Instance Manager Recovery",
+			Description: fmt.Sprintf("Instance manager %s in namespace %s has recovered", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Instance Manager", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "Node", Value: nodeID, Inline: true},
+				{Name: "State", Value: "Running", Inline: true},
+			},
+		}
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("instanceManager", name).
+			Str("namespace", namespace).
+			Msg("Longhorn instance manager has recovered")
+	}
+}
+
+// checkLonghornShareManagerRecovery checks if a share manager has recovered and sends a recovery alert
+func checkLonghornShareManagerRecovery(key, name, namespace string, kubeStatus *longhornKubernetesStatus) {
+	longhornShareManagerStatesLock.RLock()
+	prevState, exists := longhornShareManagerStates[key]
+	longhornShareManagerStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Share Manager Recovery",
+			Description: fmt.Sprintf("Share manager %s in namespace %s has recovered", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Share Manager", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "State", Value: "Running", Inline: true},
+			},
+		}
+		alert.Fields = append(alert.Fields, longhornKubernetesFields(name, kubeStatus)...)
+
+		if !routeLonghornAlert(&alert, kubeStatus) {
+			return
+		}
+
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("shareManager", name).
+			Str("namespace", namespace).
+			Msg("Longhorn share manager has recovered")
+	}
+}
+
+// checkLonghornSnapshotRecovery checks if a volume's snapshots are back within the thresholds and sends a recovery alert
+func checkLonghornSnapshotRecovery(key, name, namespace string, summary *longhornSnapshotSummary) {
+	longhornSnapshotStatesLock.RLock()
+	prevState, exists := longhornSnapshotStates[key]
+	longhornSnapshotStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Snapshot Recovery",
+			Description: fmt.Sprintf("Snapshots of volume %s in namespace %s are within thresholds again", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Volume", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "Snapshots", Value: fmt.Sprintf("%d", summary.count), Inline: true},
+				{Name: "Total Snapshot Size", Value: formatLonghornBytes(summary.totalSize), Inline: true},
+				{Name: "State", Value: "Healthy", Inline: true},
+			},
+		}
+		kubeStatus := getLonghornVolumeKubernetesStatus(namespace, name)
+		alert.Fields = append(alert.Fields, longhornKubernetesFields("", kubeStatus)...)
+
+		if !routeLonghornAlert(&alert, kubeStatus) {
+			return
+		}
+
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("volume", name).
+			Str("namespace", namespace).
+			Msg("Longhorn volume snapshots have recovered")
+	}
+}
+
+// checkLonghornSettingRecovery checks if a setting is back at its expected value and sends a recovery alert
+func checkLonghornSettingRecovery(key, name, namespace, actual string) {
+	longhornSettingStatesLock.RLock()
+	prevState, exists := longhornSettingStates[key]
+	longhornSettingStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Setting Recovery",
+			Description: fmt.Sprintf("Setting %s in namespace %s is back at its expected value", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Setting", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "Value", Value: actual, Inline: true},
+				{Name: "State", Value: "Healthy", Inline: true},
+			},
+		}
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("setting", name).
+			Str("namespace", namespace).
+			Msg("Longhorn setting has recovered")
+	}
+}
+
+// checkLonghornEngineImageRecovery checks if an engine image problem is resolved and sends a recovery alert
+func checkLonghornEngineImageRecovery(key, name, namespace, image string) {
+	longhornEngineImageStatesLock.RLock()
+	prevState, exists := longhornEngineImageStates[key]
+	longhornEngineImageStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Engine Image Recovery",
+			Description: fmt.Sprintf("Engine image %s in namespace %s has recovered", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Engine Image", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "Image", Value: image, Inline: false},
+				{Name: "State", Value: "Healthy", Inline: true},
+			},
+		}
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("engineImage", name).
+			Str("namespace", namespace).
+			Msg("Longhorn engine image has recovered")
+	}
+}
+
 // checkLonghornNodeRecovery checks if a node has recovered and sends a recovery alert
 func checkLonghornNodeRecovery(key, name string) {
 	longhornNodeStatesLock.RLock()
@@ -303,13 +920,128 @@ func checkLonghornNodeRecovery(key, name string) {
 	}
 }
 
+// checkLonghornDiskRecovery checks if a disk has recovered and sends a recovery alert
+func checkLonghornDiskRecovery(key string, info longhornDiskInfo) {
+	longhornDiskStatesLock.RLock()
+	prevState, exists := longhornDiskStates[key]
+	longhornDiskStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Disk Recovery",
+			Description: fmt.Sprintf("Disk %s on node %s has recovered", info.path, info.node),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Node", Value: info.node, Inline: true},
+				{Name: "Path", Value: info.path, Inline: true},
+				{Name: "Available", Value: formatLonghornBytes(info.storageAvailable), Inline: true},
+				{Name: "State", Value: "Schedulable", Inline: true},
+			},
+		}
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("node", info.node).
+			Str("disk", info.disk).
+			Msg("Longhorn disk has recovered")
+	}
+}
+
+// checkLonghornBackupAgeRecovery checks if a volume has a fresh backup again and sends a recovery alert
+func checkLonghornBackupAgeRecovery(key, name, namespace string, lastBackup time.Time) {
+	longhornBackupAgeStatesLock.RLock()
+	prevState, exists := longhornBackupAgeStates[key]
+	longhornBackupAgeStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Backup Age Recovery",
+			Description: fmt.Sprintf("Volume %s in namespace %s has a recent backup again", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Volume", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "Last Backup", Value: lastBackup.Format(time.RFC3339), Inline: true},
+				{Name: "State", Value: "Completed", Inline: true},
+			},
+		}
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("volume", name).
+			Str("namespace", namespace).
+			Msg("Longhorn volume backup age has recovered")
+	}
+}
+
+// checkLonghornRecurringJobRecovery checks if a RecurringJob is producing backups again and sends a recovery alert
+func checkLonghornRecurringJobRecovery(key, name, namespace string) {
+	longhornRecurringJobStatesLock.RLock()
+	prevState, exists := longhornRecurringJobStates[key]
+	longhornRecurringJobStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Recurring Job Recovery",
+			Description: fmt.Sprintf("Recurring job %s in namespace %s is producing backups again", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Recurring Job", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "State", Value: "Completed", Inline: true},
+			},
+		}
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("recurringJob", name).
+			Str("namespace", namespace).
+			Msg("Longhorn recurring job has recovered")
+	}
+}
+
+// checkLonghornBackupTargetRecovery checks if a backup target is reachable again and sends a recovery alert
+func checkLonghornBackupTargetRecovery(key, name, namespace, url string) {
+	longhornBackupTargetStatesLock.RLock()
+	prevState, exists := longhornBackupTargetStates[key]
+	longhornBackupTargetStatesLock.RUnlock()
+
+	if exists && prevState.hasError && prevState.alertSent {
+		alert := Alert{
+			Title:       "Longhorn Backup Target Recovery",
+			Description: fmt.Sprintf("Backup target %s in namespace %s is available again", name, namespace),
+			Fields: []struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{
+				{Name: "Backup Target", Value: name, Inline: true},
+				{Name: "Namespace", Value: namespace, Inline: true},
+				{Name: "URL", Value: url, Inline: false},
+				{Name: "State", Value: "Available", Inline: true},
+			},
+		}
+		sendWebhookMessage(alert)
+		log.Info().
+			Str("backupTarget", name).
+			Str("namespace", namespace).
+			Msg("Longhorn backup target has recovered")
+	}
+}
+
 // checkLonghornBackupRecovery checks if a backup has completed successfully after previous failures
 func checkLonghornBackupRecovery(key, name, namespace string) {
 	longhornBackupStatesLock.RLock()
 	prevState, exists := longhornBackupStates[key]
 	longhornBackupStatesLock.RUnlock()
 
-	if exists && prevState.hasError && prevState.alertSent {
+	if exists && prevState.hasError && prevState.alertSent && !prevState.alertSuppressed {
 		alert := Alert{
 			Title:       "Longhorn Backup Recovery",
 			Description: fmt.Sprintf("Backup %s in namespace %s has completed successfully", name, namespace),
@@ -330,3 +1062,25 @@ func checkLonghornBackupRecovery(key, name, namespace string) {
 			Msg("Longhorn backup has completed successfully")
 	}
 }
+
+// sendLonghornAvailableAlert sends an alert when a previously missing Longhorn resource type becomes available
+func sendLonghornAvailableAlert(resource string) {
+	alert := Alert{
+		Title:       "Longhorn Monitoring Available",
+		Description: fmt.Sprintf("%s.%s is now installed and being monitored", resource, longhornGroup),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Resource", Value: resource + "." + longhornGroup, Inline: true},
+			{Name: "Namespace", Value: config.Longhorn.Namespace, Inline: true},
+			{Name: "State", Value: "Available", Inline: true},
+		},
+	}
+
+	sendWebhookMessage(alert)
+	log.Info().
+		Str("resource", resource).
+		Msg("Longhorn available alert sent")
+}
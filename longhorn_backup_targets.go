@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Label Longhorn puts on Backups and BackupVolumes to name their BackupTarget
+const longhornBackupTargetLabel = "backup-target"
+
+// BackupTarget handlers
+func handleLonghornBackupTarget(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Error().Msg("Received non-unstructured object in Longhorn backup target informer")
+		return
+	}
+
+	name := unstructuredObj.GetName()
+	namespace := unstructuredObj.GetNamespace()
+
+	log.Debug().
+		Str("backupTarget", name).
+		Str("namespace", namespace).
+		Msg("Processing Longhorn backup target")
+
+	// Targets that were never configured have no URL and are always unavailable
+	url, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "backupTargetURL")
+	if url == "" {
+		log.Debug().Str("backupTarget", name).Msg("Backup target has no URL configured, skipping")
+		return
+	}
+
+	status, found, err := unstructured.NestedMap(unstructuredObj.Object, "status")
+	if err != nil || !found {
+		return
+	}
+
+	available, _, _ := unstructured.NestedBool(status, "available")
+	lastSyncedAt, _, _ := unstructured.NestedString(status, "lastSyncedAt")
+
+	// The Unavailable condition carries the reason the target can't be reached
+	var message string
+	conditions, _, _ := unstructured.NestedSlice(status, "conditions")
+	for _, conditionInterface := range conditions {
+		condition, ok := conditionInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condType, _, _ := unstructured.NestedString(condition, "type")
+		condStatus, _, _ := unstructured.NestedString(condition, "status")
+		if condType == "Unavailable" && condStatus == "True" {
+			message, _, _ = unstructured.NestedString(condition, "message")
+		}
+	}
+
+	processLonghornBackupTargetStatus(name, namespace, url, available, message, lastSyncedAt)
+}
+
+func handleLonghornBackupTargetDelete(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	longhornBackupTargetStatesLock.Lock()
+	delete(longhornBackupTargetStates, key)
+	longhornBackupTargetStatesLock.Unlock()
+}
+
+// processLonghornBackupTargetStatus processes the availability of a Longhorn backup target
+func processLonghornBackupTargetStatus(name, namespace, url string, available bool, message, lastSyncedAt string) {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+
+	log.Debug().
+		Str("backupTarget", name).
+		Str("namespace", namespace).
+		Bool("available", available).
+		Msg("Processing backup target status")
+
+	hasError := false
+	var errorMessage string
+
+	if !available {
+		hasError = true
+		errorMessage = "Backup target is unavailable"
+		if message != "" {
+			errorMessage = fmt.Sprintf("Backup target is unavailable: %s", message)
+		}
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornBackupTargetRecovery(key, name, namespace, url)
+	}
+
+	// Update state and send alerts
+	updateLonghornBackupTargetState(key, hasError, errorMessage, namespace)
+
+	if hasError && shouldSendLonghornAlert("backup_target", key) {
+		sendLonghornBackupTargetAlert(name, namespace, url, errorMessage, lastSyncedAt)
+		markLonghornAlertSent("backup_target", key)
+	}
+}
+
+// isLonghornBackupTargetDown reports whether the backup target a backup belongs to is known to be unavailable.
+// Backups from Longhorn versions with a single target carry no label, so any unavailable target counts.
+func isLonghornBackupTargetDown(targetName string) bool {
+	longhornBackupTargetStatesLock.RLock()
+	defer longhornBackupTargetStatesLock.RUnlock()
+
+	for key, state := range longhornBackupTargetStates {
+		if !state.hasError {
+			continue
+		}
+		if targetName == "" || key == fmt.Sprintf("%s/%s", state.namespace, targetName) {
+			return true
+		}
+	}
+	return false
+}
+
+func updateLonghornBackupTargetState(key string, hasError bool, errorMessage, namespace string) {
+	longhornBackupTargetStatesLock.Lock()
+	defer longhornBackupTargetStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornBackupTargetStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "backup_target",
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornBackupTargetStates[key] = newState
+}
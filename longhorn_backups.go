@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// How often backup age and RecurringJob schedules are evaluated
+const longhornBackupCheckInterval = 5 * time.Minute
+
+// Volume label prefixes Longhorn uses to attach RecurringJobs and job groups
+const (
+	longhornRecurringJobLabelPrefix      = "recurring-job.longhorn.io/"
+	longhornRecurringJobGroupLabelPrefix = "recurring-job-group.longhorn.io/"
+)
+
+// How far back the last scheduled run of a RecurringJob is searched
+var longhornRecurringJobLookbacks = []time.Duration{8 * 24 * time.Hour, 32 * 24 * time.Hour, 366 * 24 * time.Hour}
+
+// runLonghornBackupChecks periodically checks backup age per volume and RecurringJob backup windows
+func runLonghornBackupChecks(ctx context.Context) {
+	ticker := time.NewTicker(longhornBackupCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkLonghornBackupAge()
+			checkLonghornRecurringJobs()
+		}
+	}
+}
+
+// RecurringJob handlers
+func handleLonghornRecurringJob(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Error().Msg("Received non-unstructured object in Longhorn recurring job informer")
+		return
+	}
+
+	task, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "task")
+	cronSpec, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "cron")
+
+	log.Debug().
+		Str("recurringJob", unstructuredObj.GetName()).
+		Str("task", task).
+		Str("cron", cronSpec).
+		Msg("Processing Longhorn recurring job")
+}
+
+func handleLonghornRecurringJobDelete(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	longhornRecurringJobStatesLock.Lock()
+	delete(longhornRecurringJobStates, key)
+	longhornRecurringJobStatesLock.Unlock()
+}
+
+// getLonghornLastBackupTime returns the time of the last completed backup of a volume, from the
+// volume status or its BackupVolume, whichever is newer
+func getLonghornLastBackupTime(volume *unstructured.Unstructured) time.Time {
+	var lastBackup time.Time
+
+	if lastBackupAt, _, _ := unstructured.NestedString(volume.Object, "status", "lastBackupAt"); lastBackupAt != "" {
+		if t, err := time.Parse(time.RFC3339, lastBackupAt); err == nil {
+			lastBackup = t
+		}
+	}
+
+	backupVolumeInformer, ok := getLonghornInformer("backupvolumes")
+	if !ok {
+		return lastBackup
+	}
+
+	for _, obj := range backupVolumeInformer.GetStore().List() {
+		backupVolume, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		// Older Longhorn names BackupVolumes after the volume, newer versions label them instead
+		if backupVolume.GetName() != volume.GetName() && backupVolume.GetLabels()["backup-volume"] != volume.GetName() {
+			continue
+		}
+
+		lastBackupAt, _, _ := unstructured.NestedString(backupVolume.Object, "status", "lastBackupAt")
+		if t, err := time.Parse(time.RFC3339, lastBackupAt); err == nil && t.After(lastBackup) {
+			lastBackup = t
+		}
+	}
+
+	return lastBackup
+}
+
+// getLonghornBackupMaxAge returns the configured maximum backup age for a volume, 0 if unchecked
+func getLonghornBackupMaxAge(volume *unstructured.Unstructured) time.Duration {
+	for _, rule := range config.Longhorn.BackupAge.Rules {
+		matched := false
+		for _, name := range rule.Volumes {
+			if name == volume.GetName() {
+				matched = true
+				break
+			}
+		}
+
+		if !matched && rule.LabelSelector != "" {
+			selector, err := labels.Parse(rule.LabelSelector)
+			if err != nil {
+				log.Warn().Err(err).Str("selector", rule.LabelSelector).Msg("Invalid backup age label selector")
+				continue
+			}
+			matched = selector.Matches(labels.Set(volume.GetLabels()))
+		}
+
+		if matched {
+			return time.Duration(rule.MaxAgeHours) * time.Hour
+		}
+	}
+
+	return time.Duration(config.Longhorn.BackupAge.MaxAgeHours) * time.Hour
+}
+
+// checkLonghornBackupAge alerts on volumes whose last completed backup is older than allowed
+func checkLonghornBackupAge() {
+	volumeInformer, ok := getLonghornInformer("volumes")
+	if !ok {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, obj := range volumeInformer.GetStore().List() {
+		volume, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		maxAge := getLonghornBackupMaxAge(volume)
+		if maxAge <= 0 {
+			continue
+		}
+
+		name := volume.GetName()
+		namespace := volume.GetNamespace()
+		key := fmt.Sprintf("%s/%s", namespace, name)
+		seen[key] = true
+
+		lastBackup := getLonghornLastBackupTime(volume)
+
+		hasError := false
+		var errorMessage string
+
+		if lastBackup.IsZero() {
+			// New volumes get one full period before they are expected to have a backup
+			if time.Since(volume.GetCreationTimestamp().Time) > maxAge {
+				hasError = true
+				errorMessage = "Volume has never been backed up"
+			}
+		} else if age := time.Since(lastBackup); age > maxAge {
+			hasError = true
+			errorMessage = fmt.Sprintf("Last backup is %s old", age.Round(time.Minute))
+		}
+
+		log.Debug().
+			Str("volume", name).
+			Time("lastBackup", lastBackup).
+			Dur("maxAge", maxAge).
+			Bool("stale", hasError).
+			Msg("Checked Longhorn volume backup age")
+
+		if !hasError {
+			checkLonghornBackupAgeRecovery(key, name, namespace, lastBackup)
+		}
+
+		updateLonghornBackupAgeState(key, hasError, errorMessage, namespace)
+
+		if hasError && shouldSendLonghornAlert("backup_age", key) {
+			sendLonghornBackupAgeAlert(name, namespace, errorMessage, lastBackup, maxAge)
+			markLonghornAlertSent("backup_age", key)
+		}
+	}
+
+	// Forget volumes that were deleted or are no longer checked
+	longhornBackupAgeStatesLock.Lock()
+	for key := range longhornBackupAgeStates {
+		if !seen[key] {
+			delete(longhornBackupAgeStates, key)
+		}
+	}
+	longhornBackupAgeStatesLock.Unlock()
+}
+
+// longhornRecurringJobTargets returns the volumes a RecurringJob applies to, following Longhorn's label semantics
+func longhornRecurringJobTargets(jobName string, groups []string, volumes []*unstructured.Unstructured) []*unstructured.Unstructured {
+	var targets []*unstructured.Unstructured
+
+	for _, volume := range volumes {
+		volumeLabels := volume.GetLabels()
+
+		if volumeLabels[longhornRecurringJobLabelPrefix+jobName] == "enabled" {
+			targets = append(targets, volume)
+			continue
+		}
+
+		// Volumes without any job labels belong to the "default" group
+		hasJobLabels := false
+		for label := range volumeLabels {
+			if strings.HasPrefix(label, longhornRecurringJobLabelPrefix) || strings.HasPrefix(label, longhornRecurringJobGroupLabelPrefix) {
+				hasJobLabels = true
+				break
+			}
+		}
+
+		for _, group := range groups {
+			if volumeLabels[longhornRecurringJobGroupLabelPrefix+group] == "enabled" || (group == "default" && !hasJobLabels) {
+				targets = append(targets, volume)
+				break
+			}
+		}
+	}
+
+	return targets
+}
+
+// lastScheduledRun returns the most recent time the schedule fired at or before the given time
+func lastScheduledRun(schedule cron.Schedule, before time.Time) (time.Time, bool) {
+	for _, lookback := range longhornRecurringJobLookbacks {
+		var last time.Time
+		for t := schedule.Next(before.Add(-lookback)); !t.IsZero() && !t.After(before); t = schedule.Next(t) {
+			last = t
+		}
+		if !last.IsZero() {
+			return last, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// checkLonghornRecurringJobs alerts on backup RecurringJobs whose last scheduled run produced no backup
+func checkLonghornRecurringJobs() {
+	jobInformer, ok := getLonghornInformer("recurringjobs")
+	if !ok {
+		return
+	}
+	volumeInformer, ok := getLonghornInformer("volumes")
+	if !ok {
+		return
+	}
+
+	var volumes []*unstructured.Unstructured
+	for _, obj := range volumeInformer.GetStore().List() {
+		if volume, ok := obj.(*unstructured.Unstructured); ok {
+			volumes = append(volumes, volume)
+		}
+	}
+
+	grace := time.Duration(config.Longhorn.BackupAge.RecurringJobGraceMinutes) * time.Minute
+	now := time.Now()
+
+	for _, obj := range jobInformer.GetStore().List() {
+		job, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		name := job.GetName()
+		namespace := job.GetNamespace()
+		key := fmt.Sprintf("%s/%s", namespace, name)
+
+		task, _, _ := unstructured.NestedString(job.Object, "spec", "task")
+		if task != "backup" && task != "backup-force-create" {
+			continue
+		}
+
+		cronSpec, _, _ := unstructured.NestedString(job.Object, "spec", "cron")
+		schedule, err := cron.ParseStandard(cronSpec)
+		if err != nil {
+			log.Warn().Err(err).Str("recurringJob", name).Str("cron", cronSpec).Msg("Failed to parse RecurringJob schedule")
+			continue
+		}
+
+		// Give the run that was due most recently some time to complete
+		expectedRun, found := lastScheduledRun(schedule, now.Add(-grace))
+		if !found {
+			continue
+		}
+
+		groups, _, _ := unstructured.NestedStringSlice(job.Object, "spec", "groups")
+		var missing []string
+		for _, volume := range longhornRecurringJobTargets(name, groups, longhornSettledVolumes(volumes)) {
+			// Volumes created after the run was due can't have a backup from it
+			if volume.GetCreationTimestamp().Time.After(expectedRun) {
+				continue
+			}
+			if getLonghornLastBackupTime(volume).Before(expectedRun.Add(-time.Minute)) {
+				missing = append(missing, volume.GetName())
+			}
+		}
+		sort.Strings(missing)
+
+		hasError := len(missing) > 0
+		var errorMessage string
+		if hasError {
+			errorMessage = fmt.Sprintf("No backup since scheduled run at %s for %d volume(s)", expectedRun.Format(time.RFC3339), len(missing))
+		}
+
+		log.Debug().
+			Str("recurringJob", name).
+			Time("expectedRun", expectedRun).
+			Int("missing", len(missing)).
+			Msg("Checked Longhorn recurring job")
+
+		if !hasError {
+			checkLonghornRecurringJobRecovery(key, name, namespace)
+		}
+
+		updateLonghornRecurringJobState(key, hasError, errorMessage, namespace)
+
+		if hasError && shouldSendLonghornAlert("recurring_job", key) {
+			sendLonghornRecurringJobAlert(name, namespace, cronSpec, expectedRun, missing)
+			markLonghornAlertSent("recurring_job", key)
+		}
+	}
+}
+
+// longhornSettledVolumes filters out volumes that are still being created or deleted
+func longhornSettledVolumes(volumes []*unstructured.Unstructured) []*unstructured.Unstructured {
+	var filtered []*unstructured.Unstructured
+	for _, volume := range volumes {
+		state, _, _ := unstructured.NestedString(volume.Object, "status", "state")
+		if state == "attached" || state == "detached" {
+			filtered = append(filtered, volume)
+		}
+	}
+	return filtered
+}
+
+func updateLonghornBackupAgeState(key string, hasError bool, errorMessage, namespace string) {
+	longhornBackupAgeStatesLock.Lock()
+	defer longhornBackupAgeStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornBackupAgeStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "backup_age",
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornBackupAgeStates[key] = newState
+}
+
+func updateLonghornRecurringJobState(key string, hasError bool, errorMessage, namespace string) {
+	longhornRecurringJobStatesLock.Lock()
+	defer longhornRecurringJobStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornRecurringJobStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "recurring_job",
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornRecurringJobStates[key] = newState
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Longhorn setting that limits how much storage can be scheduled on a disk
+const longhornOverProvisioningSetting = "storage-over-provisioning-percentage"
+
+// Longhorn's own default for storage-over-provisioning-percentage
+const longhornDefaultOverProvisioningPercent = 100
+
+// longhornDiskInfo holds the capacity figures of a single Longhorn disk
+type longhornDiskInfo struct {
+	node              string
+	disk              string
+	path              string
+	storageAvailable  int64
+	storageMaximum    int64
+	storageScheduled  int64
+	storageReserved   int64
+	overProvisioning  int64
+	schedulable       bool
+	unschedulableInfo string
+}
+
+// processLonghornDiskStatus checks every disk of a Longhorn node for schedulability, free space and overcommit
+func processLonghornDiskStatus(nodeName string, disks, diskStatus map[string]interface{}) {
+	overProvisioning := getLonghornOverProvisioningPercent()
+	seen := make(map[string]bool)
+
+	for diskName, statusInterface := range diskStatus {
+		status, ok := statusInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		info := longhornDiskInfo{
+			node:             nodeName,
+			disk:             diskName,
+			overProvisioning: overProvisioning,
+			schedulable:      true,
+		}
+		info.storageAvailable, _, _ = unstructured.NestedInt64(status, "storageAvailable")
+		info.storageMaximum, _, _ = unstructured.NestedInt64(status, "storageMaximum")
+		info.storageScheduled, _, _ = unstructured.NestedInt64(status, "storageScheduled")
+
+		// Newer Longhorn versions report the path in the status, older ones only in the spec
+		info.path, _, _ = unstructured.NestedString(status, "diskPath")
+		if spec, ok := disks[diskName].(map[string]interface{}); ok {
+			if info.path == "" {
+				info.path, _, _ = unstructured.NestedString(spec, "path")
+			}
+			info.storageReserved, _, _ = unstructured.NestedInt64(spec, "storageReserved")
+		}
+		if info.path == "" {
+			info.path = diskName
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(status, "conditions")
+		for _, conditionInterface := range conditions {
+			condition, ok := conditionInterface.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			condType, _, _ := unstructured.NestedString(condition, "type")
+			condStatus, _, _ := unstructured.NestedString(condition, "status")
+			if condType == "Schedulable" && condStatus != "True" {
+				info.schedulable = false
+				reason, _, _ := unstructured.NestedString(condition, "reason")
+				message, _, _ := unstructured.NestedString(condition, "message")
+				info.unschedulableInfo = strings.TrimSpace(reason + " " + message)
+			}
+		}
+
+		key := fmt.Sprintf("%s/%s", nodeName, diskName)
+		seen[key] = true
+		processLonghornDisk(key, info)
+	}
+
+	// Forget disks that were removed from the node
+	cleanupLonghornDiskStates(nodeName, seen)
+}
+
+// processLonghornDisk evaluates a single disk and sends alerts if necessary
+func processLonghornDisk(key string, info longhornDiskInfo) {
+	log.Debug().
+		Str("node", info.node).
+		Str("disk", info.disk).
+		Str("path", info.path).
+		Int64("storageAvailable", info.storageAvailable).
+		Int64("storageMaximum", info.storageMaximum).
+		Int64("storageScheduled", info.storageScheduled).
+		Msg("Processing disk status")
+
+	hasError := false
+	var errorMessage string
+	var alertType string
+
+	if !info.schedulable {
+		hasError = true
+		errorMessage = "Disk is unschedulable"
+		if info.unschedulableInfo != "" {
+			errorMessage = fmt.Sprintf("Disk is unschedulable: %s", info.unschedulableInfo)
+		}
+		alertType = "unschedulable"
+	}
+
+	// Check scheduled storage against the over-provisioning limit
+	schedulableLimit := (info.storageMaximum - info.storageReserved) * info.overProvisioning / 100
+	if info.storageMaximum > 0 && info.storageScheduled > schedulableLimit {
+		hasError = true
+		errorMessage = fmt.Sprintf("Disk overcommitted: %d bytes scheduled, limit %d bytes at %d%% over-provisioning",
+			info.storageScheduled, schedulableLimit, info.overProvisioning)
+		alertType = "overcommitted"
+	}
+
+	// Check available space
+	if info.storageMaximum > 0 {
+		availablePercent := float64(info.storageAvailable) / float64(info.storageMaximum) * 100
+
+		if availablePercent < config.Longhorn.AlertThresholds.DiskAvailablePercent {
+			hasError = true
+			errorMessage = fmt.Sprintf("Disk space low: %.1f%% available", availablePercent)
+			alertType = "space_low"
+		} else if info.storageAvailable < config.Longhorn.AlertThresholds.DiskAvailableCritical {
+			hasError = true
+			errorMessage = fmt.Sprintf("Disk space critical: %d bytes available", info.storageAvailable)
+			alertType = "space_critical"
+		}
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornDiskRecovery(key, info)
+	}
+
+	// Update state and send alerts
+	updateLonghornDiskState(key, hasError, errorMessage, info)
+
+	if hasError && shouldSendLonghornAlert("disk", key) {
+		sendLonghornDiskAlert(info, errorMessage, alertType)
+		markLonghornAlertSent("disk", key)
+	}
+}
+
+// getLonghornOverProvisioningPercent reads Longhorn's over-provisioning setting, falling back to its default
+func getLonghornOverProvisioningPercent() int64 {
+	value, ok := getLonghornSetting(longhornOverProvisioningSetting)
+	if !ok {
+		return longhornDefaultOverProvisioningPercent
+	}
+
+	percent, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || percent <= 0 {
+		log.Debug().Str("value", value).Msg("Failed to parse Longhorn over-provisioning setting")
+		return longhornDefaultOverProvisioningPercent
+	}
+
+	return percent
+}
+
+func updateLonghornDiskState(key string, hasError bool, errorMessage string, info longhornDiskInfo) {
+	longhornDiskStatesLock.Lock()
+	defer longhornDiskStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornDiskStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "disk",
+		capacity:     info.storageMaximum,
+		usage:        info.storageMaximum - info.storageAvailable,
+		node:         info.node,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornDiskStates[key] = newState
+}
+
+// cleanupLonghornDiskStates removes disk states of a node, except for the keys in keep
+func cleanupLonghornDiskStates(nodeName string, keep map[string]bool) {
+	longhornDiskStatesLock.Lock()
+	defer longhornDiskStatesLock.Unlock()
+
+	for key, state := range longhornDiskStates {
+		if state.node == nodeName && !keep[key] {
+			delete(longhornDiskStates, key)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Longhorn setting naming the engine image new and upgraded volumes should run
+const longhornDefaultEngineImageSetting = "default-engine-image"
+
+// How often engine image deployment and outdated volumes are evaluated
+const longhornEngineImageCheckInterval = 5 * time.Minute
+
+// Outdated volume alerts list at most this many volume names
+const longhornOutdatedVolumesListed = 20
+
+// runLonghornEngineImageChecks periodically re-evaluates engine images, so the upgrade grace period can expire without events
+func runLonghornEngineImageChecks(ctx context.Context) {
+	ticker := time.NewTicker(longhornEngineImageCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkLonghornEngineImages()
+		}
+	}
+}
+
+// EngineImage handlers
+func handleLonghornEngineImage(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Error().Msg("Received non-unstructured object in Longhorn engine image informer")
+		return
+	}
+
+	log.Debug().
+		Str("engineImage", unstructuredObj.GetName()).
+		Str("namespace", unstructuredObj.GetNamespace()).
+		Msg("Processing Longhorn engine image")
+
+	checkLonghornEngineImages()
+}
+
+func handleLonghornEngineImageDelete(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	longhornEngineImageStatesLock.Lock()
+	delete(longhornEngineImageStates, key)
+	longhornEngineImageStatesLock.Unlock()
+}
+
+// checkLonghornEngineImages checks deployment of every engine image in use and volumes left on outdated images
+func checkLonghornEngineImages() {
+	engineImageInformer, ok := getLonghornInformer("engineimages")
+	if !ok || !engineImageInformer.HasSynced() {
+		return
+	}
+
+	defaultImage, _ := getLonghornSetting(longhornDefaultEngineImageSetting)
+	longhornNodes := getSchedulableLonghornNodes()
+
+	var defaultEngineImage *unstructured.Unstructured
+	for _, obj := range engineImageInformer.GetStore().List() {
+		engineImage, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		image, _, _ := unstructured.NestedString(engineImage.Object, "spec", "image")
+		isDefault := image != "" && image == defaultImage
+		if isDefault {
+			defaultEngineImage = engineImage
+		}
+
+		// Old images nothing uses anymore are left behind after upgrades and don't need to be deployed
+		refCount, _, _ := unstructured.NestedInt64(engineImage.Object, "status", "refCount")
+		if refCount == 0 && !isDefault {
+			continue
+		}
+
+		state, _, _ := unstructured.NestedString(engineImage.Object, "status", "state")
+		deployment, _, _ := unstructured.NestedMap(engineImage.Object, "status", "nodeDeploymentMap")
+
+		var notDeployed []string
+		for _, node := range longhornNodes {
+			if deployed, ok := deployment[node].(bool); !ok || !deployed {
+				notDeployed = append(notDeployed, node)
+			}
+		}
+
+		processLonghornEngineImageStatus(engineImage.GetName(), engineImage.GetNamespace(), image, state, refCount, notDeployed)
+	}
+
+	if defaultEngineImage != nil {
+		checkLonghornOutdatedVolumes(defaultEngineImage, defaultImage)
+	}
+}
+
+// getSchedulableLonghornNodes returns the Longhorn nodes an engine image is expected to be deployed on
+func getSchedulableLonghornNodes() []string {
+	nodeInformer, ok := getLonghornInformer("nodes")
+	if !ok {
+		return nil
+	}
+
+	var nodes []string
+	for _, obj := range nodeInformer.GetStore().List() {
+		node, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		// Nodes that are down are already alerted on and can't deploy anything
+		ready := false
+		conditions, _, _ := unstructured.NestedSlice(node.Object, "status", "conditions")
+		for _, conditionInterface := range conditions {
+			condition, ok := conditionInterface.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			condType, _, _ := unstructured.NestedString(condition, "type")
+			condStatus, _, _ := unstructured.NestedString(condition, "status")
+			if condType == "Ready" && condStatus == "True" {
+				ready = true
+			}
+		}
+		if ready {
+			nodes = append(nodes, node.GetName())
+		}
+	}
+
+	sort.Strings(nodes)
+	return nodes
+}
+
+// processLonghornEngineImageStatus processes the deployment status of a Longhorn engine image
+func processLonghornEngineImageStatus(name, namespace, image, state string, refCount int64, notDeployed []string) {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+
+	log.Debug().
+		Str("engineImage", name).
+		Str("state", state).
+		Int("notDeployed", len(notDeployed)).
+		Msg("Processing engine image status")
+
+	hasError := false
+	var errorMessage string
+
+	if state != "deployed" {
+		hasError = true
+		errorMessage = fmt.Sprintf("Engine image in %s state", state)
+	}
+	if len(notDeployed) > 0 {
+		hasError = true
+		errorMessage = fmt.Sprintf("Engine image not deployed on %d node(s)", len(notDeployed))
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornEngineImageRecovery(key, name, namespace, image)
+	}
+
+	// Update state and send alerts
+	updateLonghornEngineImageState(key, hasError, errorMessage, namespace)
+
+	if hasError && shouldSendLonghornAlert("engine_image", key) {
+		sendLonghornEngineImageAlert(name, namespace, image, state, refCount, notDeployed, errorMessage)
+		markLonghornAlertSent("engine_image", key)
+	}
+}
+
+// checkLonghornOutdatedVolumes alerts on volumes still running an older engine image once the upgrade grace period expired
+func checkLonghornOutdatedVolumes(defaultEngineImage *unstructured.Unstructured, defaultImage string) {
+	grace := time.Duration(config.Longhorn.AlertThresholds.EngineImageGraceHours) * time.Hour
+	if grace <= 0 {
+		return
+	}
+
+	volumeInformer, ok := getLonghornInformer("volumes")
+	if !ok {
+		return
+	}
+
+	namespace := defaultEngineImage.GetNamespace()
+	key := fmt.Sprintf("%s/outdated-volumes", namespace)
+
+	// The default engine image is created when Longhorn is upgraded, which starts the grace period
+	upgradedAt := defaultEngineImage.GetCreationTimestamp().Time
+
+	var outdated []string
+	if time.Since(upgradedAt) > grace {
+		for _, obj := range volumeInformer.GetStore().List() {
+			volume, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			currentImage, _, _ := unstructured.NestedString(volume.Object, "status", "currentImage")
+			if currentImage != "" && currentImage != defaultImage {
+				outdated = append(outdated, volume.GetName())
+			}
+		}
+		sort.Strings(outdated)
+	}
+
+	hasError := len(outdated) > 0
+	var errorMessage string
+	if hasError {
+		errorMessage = fmt.Sprintf("%d volume(s) still running an outdated engine image", len(outdated))
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornEngineImageRecovery(key, "outdated volumes", namespace, defaultImage)
+	}
+
+	// Update state and send alerts
+	updateLonghornEngineImageState(key, hasError, errorMessage, namespace)
+
+	if hasError && shouldSendLonghornAlert("engine_image", key) {
+		sendLonghornOutdatedVolumesAlert(namespace, defaultImage, upgradedAt, outdated, errorMessage)
+		markLonghornAlertSent("engine_image", key)
+	}
+}
+
+// formatLonghornVolumeList renders volume names for an alert field, truncating long lists
+func formatLonghornVolumeList(volumes []string) string {
+	if len(volumes) <= longhornOutdatedVolumesListed {
+		return strings.Join(volumes, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(volumes[:longhornOutdatedVolumesListed], ", "), len(volumes)-longhornOutdatedVolumesListed)
+}
+
+func updateLonghornEngineImageState(key string, hasError bool, errorMessage, namespace string) {
+	longhornEngineImageStatesLock.Lock()
+	defer longhornEngineImageStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornEngineImageStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "engine_image",
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornEngineImageStates[key] = newState
+}
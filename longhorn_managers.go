@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// InstanceManager handlers
+func handleLonghornInstanceManager(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Error().Msg("Received non-unstructured object in Longhorn instance manager informer")
+		return
+	}
+
+	name := unstructuredObj.GetName()
+	namespace := unstructuredObj.GetNamespace()
+
+	log.Debug().
+		Str("instanceManager", name).
+		Str("namespace", namespace).
+		Msg("Processing Longhorn instance manager")
+
+	status, found, err := unstructured.NestedMap(unstructuredObj.Object, "status")
+	if err != nil || !found {
+		return
+	}
+
+	currentState, _, _ := unstructured.NestedString(status, "currentState")
+	nodeID, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "nodeID")
+	managerType, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "type")
+
+	// Longhorn 1.5+ splits instances by kind, older versions keep a single map
+	engines, _, _ := unstructured.NestedMap(status, "instanceEngines")
+	replicas, _, _ := unstructured.NestedMap(status, "instanceReplicas")
+	instances := len(engines) + len(replicas)
+	if instances == 0 {
+		legacyInstances, _, _ := unstructured.NestedMap(status, "instances")
+		instances = len(legacyInstances)
+	}
+
+	processLonghornInstanceManagerStatus(name, namespace, currentState, nodeID, managerType, instances)
+}
+
+func handleLonghornInstanceManagerDelete(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	longhornInstanceManagerStatesLock.Lock()
+	delete(longhornInstanceManagerStates, key)
+	longhornInstanceManagerStatesLock.Unlock()
+}
+
+// ShareManager handlers
+func handleLonghornShareManager(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Error().Msg("Received non-unstructured object in Longhorn share manager informer")
+		return
+	}
+
+	name := unstructuredObj.GetName()
+	namespace := unstructuredObj.GetNamespace()
+
+	log.Debug().
+		Str("shareManager", name).
+		Str("namespace", namespace).
+		Msg("Processing Longhorn share manager")
+
+	status, found, err := unstructured.NestedMap(unstructuredObj.Object, "status")
+	if err != nil || !found {
+		return
+	}
+
+	state, _, _ := unstructured.NestedString(status, "state")
+	endpoint, _, _ := unstructured.NestedString(status, "endpoint")
+
+	// A share manager is named after the RWX volume it exports
+	kubeStatus := getLonghornVolumeKubernetesStatus(namespace, name)
+
+	processLonghornShareManagerStatus(name, namespace, state, endpoint, kubeStatus)
+}
+
+func handleLonghornShareManagerDelete(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	longhornShareManagerStatesLock.Lock()
+	delete(longhornShareManagerStates, key)
+	longhornShareManagerStatesLock.Unlock()
+}
+
+// processLonghornInstanceManagerStatus processes the status of a Longhorn instance manager
+func processLonghornInstanceManagerStatus(name, namespace, currentState, nodeID, managerType string, instances int) {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+
+	log.Debug().
+		Str("instanceManager", name).
+		Str("namespace", namespace).
+		Str("state", currentState).
+		Str("node", nodeID).
+		Msg("Processing instance manager status")
+
+	hasError := false
+	var errorMessage string
+
+	switch currentState {
+	case "running":
+		// Healthy state
+	case "starting", "pending", "stopped":
+		// Instance managers are recreated during upgrades and node restarts
+		log.Debug().Str("instanceManager", name).Str("state", currentState).Msg("Instance manager in transitional state")
+	case "error":
+		hasError = true
+		errorMessage = "Instance manager in error state"
+	default:
+		hasError = true
+		errorMessage = fmt.Sprintf("Instance manager in unknown state: %s", currentState)
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornInstanceManagerRecovery(key, name, namespace, nodeID)
+	}
+
+	// Update state and send alerts
+	updateLonghornInstanceManagerState(key, hasError, errorMessage, namespace, nodeID)
+
+	if hasError && shouldSendLonghornAlert("instance_manager", key) {
+		sendLonghornInstanceManagerAlert(name, namespace, currentState, nodeID, managerType, instances, errorMessage)
+		markLonghornAlertSent("instance_manager", key)
+	}
+}
+
+// processLonghornShareManagerStatus processes the status of a Longhorn share manager
+func processLonghornShareManagerStatus(name, namespace, state, endpoint string, kubeStatus *longhornKubernetesStatus) {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+
+	log.Debug().
+		Str("shareManager", name).
+		Str("namespace", namespace).
+		Str("state", state).
+		Msg("Processing share manager status")
+
+	hasError := false
+	var errorMessage string
+
+	switch state {
+	case "running":
+		// Healthy state
+	case "stopped":
+		// Stopped while no workload has the RWX volume mounted
+	case "starting", "stopping":
+		log.Debug().Str("shareManager", name).Str("state", state).Msg("Share manager in transitional state")
+	case "error":
+		hasError = true
+		errorMessage = "Share manager in error state, the RWX volume can't be mounted"
+	default:
+		hasError = true
+		errorMessage = fmt.Sprintf("Share manager in unknown state: %s", state)
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornShareManagerRecovery(key, name, namespace, kubeStatus)
+	}
+
+	// Update state and send alerts
+	updateLonghornShareManagerState(key, hasError, errorMessage, namespace)
+
+	if hasError && shouldSendLonghornAlert("share_manager", key) {
+		sendLonghornShareManagerAlert(name, namespace, state, endpoint, errorMessage, kubeStatus)
+		markLonghornAlertSent("share_manager", key)
+	}
+}
+
+func updateLonghornInstanceManagerState(key string, hasError bool, errorMessage, namespace, nodeID string) {
+	longhornInstanceManagerStatesLock.Lock()
+	defer longhornInstanceManagerStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornInstanceManagerStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "instance_manager",
+		node:         nodeID,
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornInstanceManagerStates[key] = newState
+}
+
+func updateLonghornShareManagerState(key string, hasError bool, errorMessage, namespace string) {
+	longhornShareManagerStatesLock.Lock()
+	defer longhornShareManagerStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornShareManagerStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "share_manager",
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornShareManagerStates[key] = newState
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Kubernetes node label used as a fallback when Longhorn doesn't report a zone
+const zoneLabel = "topology.kubernetes.io/zone"
+
+// Rebuild progress is reported each time it crosses one of these steps
+const longhornRebuildProgressStep = 25
+
+// longhornReplicationStatus summarizes replica health and placement for a volume
+type longhornReplicationStatus struct {
+	desired        int64
+	healthy        int
+	colocatedNodes []string
+	colocatedZones []string
+	rebuilds       []longhornRebuild
+}
+
+// longhornRebuild is one entry of an engine's status.rebuildStatus
+type longhornRebuild struct {
+	replicaAddress string
+	progress       int64
+	state          string
+	errorMessage   string
+}
+
+// rebuildProgress returns the lowest progress of all running rebuilds, or -1 if nothing is rebuilding
+func (r *longhornReplicationStatus) rebuildProgress() int64 {
+	if r == nil || len(r.rebuilds) == 0 {
+		return -1
+	}
+	lowest := r.rebuilds[0].progress
+	for _, rebuild := range r.rebuilds[1:] {
+		if rebuild.progress < lowest {
+			lowest = rebuild.progress
+		}
+	}
+	return lowest
+}
+
+// Informer index of replicas, engines and snapshots by the volume they belong to
+const longhornVolumeNameIndex = "volumeName"
+
+func longhornVolumeNameIndexFunc(obj interface{}) ([]string, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil
+	}
+	volumeName, _, _ := unstructured.NestedString(u.Object, "spec", "volumeName")
+	if volumeName == "" {
+		return nil, nil
+	}
+	return []string{volumeName}, nil
+}
+
+// listLonghornObjectsForVolume returns the cached replicas, engines or snapshots of a volume
+func listLonghornObjectsForVolume(informer cache.SharedIndexInformer, volumeName string) []interface{} {
+	objs, err := informer.GetIndexer().ByIndex(longhornVolumeNameIndex, volumeName)
+	if err != nil {
+		log.Debug().Err(err).Str("volume", volumeName).Msg("Failed to look up Longhorn objects by volume")
+		return nil
+	}
+	return objs
+}
+
+// getLonghornReplicationStatus compares a volume's desired replica count with the replica informer cache.
+// It returns nil when replicas aren't being monitored, since nothing can be said about them.
+func getLonghornReplicationStatus(volumeName string, desired int64) *longhornReplicationStatus {
+	replicaInformer, ok := getLonghornInformer("replicas")
+	if !ok || !replicaInformer.HasSynced() || desired <= 0 {
+		return nil
+	}
+
+	status := &longhornReplicationStatus{desired: desired}
+	replicasPerNode := make(map[string]int)
+	replicasPerZone := make(map[string]int)
+
+	for _, obj := range listLonghornObjectsForVolume(replicaInformer, volumeName) {
+		replica, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		// A replica only counts once it has finished rebuilding and hasn't failed since
+		currentState, _, _ := unstructured.NestedString(replica.Object, "status", "currentState")
+		healthyAt, _, _ := unstructured.NestedString(replica.Object, "spec", "healthyAt")
+		failedAt, _, _ := unstructured.NestedString(replica.Object, "spec", "failedAt")
+		if currentState != "running" || healthyAt == "" || failedAt != "" {
+			continue
+		}
+
+		status.healthy++
+
+		nodeID, _, _ := unstructured.NestedString(replica.Object, "spec", "nodeID")
+		if nodeID == "" {
+			continue
+		}
+		replicasPerNode[nodeID]++
+		if zone := getLonghornNodeZone(replica.GetNamespace(), nodeID); zone != "" {
+			replicasPerZone[zone]++
+		}
+	}
+
+	for node, count := range replicasPerNode {
+		if count > 1 {
+			status.colocatedNodes = append(status.colocatedNodes, node)
+		}
+	}
+	sort.Strings(status.colocatedNodes)
+
+	// Sharing a zone is only a violation if there were enough zones to spread across
+	if zones := countLonghornZones(); zones > 1 && int64(zones) >= desired {
+		for zone, count := range replicasPerZone {
+			if count > 1 {
+				status.colocatedZones = append(status.colocatedZones, zone)
+			}
+		}
+		sort.Strings(status.colocatedZones)
+	}
+
+	status.rebuilds = getLonghornRebuilds(volumeName)
+
+	return status
+}
+
+// getLonghornRebuilds reads the rebuild status of the engine serving a volume
+func getLonghornRebuilds(volumeName string) []longhornRebuild {
+	engineInformer, ok := getLonghornInformer("engines")
+	if !ok {
+		return nil
+	}
+
+	var rebuilds []longhornRebuild
+	for _, obj := range listLonghornObjectsForVolume(engineInformer, volumeName) {
+		engine, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		rebuildStatus, found, _ := unstructured.NestedMap(engine.Object, "status", "rebuildStatus")
+		if !found {
+			continue
+		}
+
+		for address, entry := range rebuildStatus {
+			rebuild, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			isRebuilding, _, _ := unstructured.NestedBool(rebuild, "isRebuilding")
+			errorMessage, _, _ := unstructured.NestedString(rebuild, "error")
+			if !isRebuilding && errorMessage == "" {
+				continue
+			}
+
+			progress, _, _ := unstructured.NestedInt64(rebuild, "progress")
+			state, _, _ := unstructured.NestedString(rebuild, "state")
+			rebuilds = append(rebuilds, longhornRebuild{
+				replicaAddress: address,
+				progress:       progress,
+				state:          state,
+				errorMessage:   errorMessage,
+			})
+		}
+	}
+
+	sort.Slice(rebuilds, func(i, j int) bool {
+		return rebuilds[i].replicaAddress < rebuilds[j].replicaAddress
+	})
+	return rebuilds
+}
+
+// getLonghornNodeZone returns the zone of a node from the Longhorn node CR, falling back to the Kubernetes node label
+func getLonghornNodeZone(namespace, nodeName string) string {
+	if nodeInformer, ok := getLonghornInformer("nodes"); ok {
+		if obj, exists, err := nodeInformer.GetStore().GetByKey(namespace + "/" + nodeName); err == nil && exists {
+			if node, ok := obj.(*unstructured.Unstructured); ok {
+				if zone, _, _ := unstructured.NestedString(node.Object, "status", "zone"); zone != "" {
+					return zone
+				}
+			}
+		}
+	}
+
+	if nodeLister != nil {
+		if node, err := nodeLister.Get(nodeName); err == nil {
+			return node.Labels[zoneLabel]
+		}
+	}
+
+	return ""
+}
+
+// countLonghornZones returns the number of distinct zones across Longhorn nodes
+func countLonghornZones() int {
+	nodeInformer, ok := getLonghornInformer("nodes")
+	if !ok {
+		return 0
+	}
+
+	zones := make(map[string]bool)
+	for _, obj := range nodeInformer.GetStore().List() {
+		node, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if zone := getLonghornNodeZone(node.GetNamespace(), node.GetName()); zone != "" {
+			zones[zone] = true
+		}
+	}
+	return len(zones)
+}
+
+// reevaluateLonghornVolume re-runs the volume checks on the latest cached volume, e.g. after one of its replicas changed
+func reevaluateLonghornVolume(namespace, volumeName string) {
+	if volumeName == "" {
+		return
+	}
+
+	volumeInformer, ok := getLonghornInformer("volumes")
+	if !ok {
+		return
+	}
+
+	obj, exists, err := volumeInformer.GetStore().GetByKey(namespace + "/" + volumeName)
+	if err != nil || !exists {
+		return
+	}
+
+	log.Debug().
+		Str("volume", volumeName).
+		Str("namespace", namespace).
+		Msg("Re-evaluating Longhorn volume")
+
+	handleLonghornVolume(obj)
+}
+
+// checkLonghornRebuildProgress reports rebuild progress for a volume that has already been alerted on
+func checkLonghornRebuildProgress(key, name, namespace string, replication *longhornReplicationStatus, kubeStatus *longhornKubernetesStatus) {
+	progress := replication.rebuildProgress()
+	if progress < 0 {
+		return
+	}
+
+	longhornVolumeStatesLock.Lock()
+	state, exists := longhornVolumeStates[key]
+	if !exists || !state.hasError || !state.alertSent {
+		longhornVolumeStatesLock.Unlock()
+		return
+	}
+
+	step := progress / longhornRebuildProgressStep * longhornRebuildProgressStep
+	if step <= state.rebuildProgress {
+		longhornVolumeStatesLock.Unlock()
+		return
+	}
+	state.rebuildProgress = step
+	longhornVolumeStates[key] = state
+	longhornVolumeStatesLock.Unlock()
+
+	sendLonghornRebuildProgressAlert(name, namespace, replication, kubeStatus)
+}
+
+// formatLonghornRebuilds renders rebuild entries for an alert field
+func formatLonghornRebuilds(rebuilds []longhornRebuild) string {
+	var lines []string
+	for _, rebuild := range rebuilds {
+		line := fmt.Sprintf("%s: %d%%", rebuild.replicaAddress, rebuild.progress)
+		if rebuild.state != "" {
+			line += fmt.Sprintf(" (%s)", rebuild.state)
+		}
+		if rebuild.errorMessage != "" {
+			line += fmt.Sprintf(" error: %s", rebuild.errorMessage)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\\n")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Setting handlers
+func handleLonghornSetting(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Error().Msg("Received non-unstructured object in Longhorn setting informer")
+		return
+	}
+
+	name := unstructuredObj.GetName()
+	expected, exists := getExpectedLonghornSetting(name)
+	if !exists {
+		return
+	}
+
+	value, _, _ := unstructured.NestedString(unstructuredObj.Object, "value")
+
+	processLonghornSettingStatus(name, unstructuredObj.GetNamespace(), expected, value)
+}
+
+func handleLonghornSettingDelete(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	longhornSettingStatesLock.Lock()
+	delete(longhornSettingStates, key)
+	longhornSettingStatesLock.Unlock()
+}
+
+// getExpectedLonghornSetting returns the desired value of a setting from longhorn.expected_settings.
+// Values are formatted so unquoted YAML booleans and numbers compare like Longhorn's string values.
+func getExpectedLonghornSetting(name string) (string, bool) {
+	for settingName, value := range config.Longhorn.ExpectedSettings {
+		if strings.EqualFold(settingName, name) {
+			return fmt.Sprint(value), true
+		}
+	}
+	return "", false
+}
+
+// processLonghornSettingStatus compares a Longhorn setting with its expected value
+func processLonghornSettingStatus(name, namespace, expected, actual string) {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+
+	log.Debug().
+		Str("setting", name).
+		Str("expected", expected).
+		Str("actual", actual).
+		Msg("Processing setting status")
+
+	hasError := false
+	var errorMessage string
+
+	if !strings.EqualFold(strings.TrimSpace(actual), strings.TrimSpace(expected)) {
+		hasError = true
+		errorMessage = fmt.Sprintf("Setting drifted from the expected value %q to %q", expected, actual)
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornSettingRecovery(key, name, namespace, actual)
+	}
+
+	// Update state and send alerts
+	updateLonghornSettingState(key, hasError, errorMessage, namespace)
+
+	if hasError && shouldSendLonghornAlert("setting", key) {
+		sendLonghornSettingAlert(name, namespace, expected, actual, errorMessage)
+		markLonghornAlertSent("setting", key)
+	}
+}
+
+func updateLonghornSettingState(key string, hasError bool, errorMessage, namespace string) {
+	longhornSettingStatesLock.Lock()
+	defer longhornSettingStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornSettingStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "setting",
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornSettingStates[key] = newState
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// How often snapshot thresholds are re-evaluated, snapshots alone change too rarely to drive the debounce
+const longhornSnapshotCheckInterval = 5 * time.Minute
+
+// RecurringJob tasks that remove snapshots Longhorn no longer needs
+var longhornSnapshotCleanupTasks = []string{"snapshot-cleanup", "snapshot-delete"}
+
+// longhornSnapshotInfo is a single snapshot of a volume
+type longhornSnapshotInfo struct {
+	name         string
+	size         int64
+	creationTime time.Time
+}
+
+// longhornSnapshotSummary aggregates the snapshots of a single volume
+type longhornSnapshotSummary struct {
+	count     int
+	totalSize int64
+	oldest    *longhornSnapshotInfo
+	largest   *longhornSnapshotInfo
+}
+
+// runLonghornSnapshotChecks periodically checks snapshot count and size of every volume
+func runLonghornSnapshotChecks(ctx context.Context) {
+	ticker := time.NewTicker(longhornSnapshotCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkAllLonghornVolumeSnapshots()
+		}
+	}
+}
+
+// Snapshot handlers
+func handleLonghornSnapshot(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Error().Msg("Received non-unstructured object in Longhorn snapshot informer")
+		return
+	}
+
+	volumeName, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "volume")
+
+	log.Debug().
+		Str("snapshot", unstructuredObj.GetName()).
+		Str("volume", volumeName).
+		Msg("Processing Longhorn snapshot")
+
+	checkLonghornVolumeSnapshots(unstructuredObj.GetNamespace(), volumeName)
+}
+
+func handleLonghornSnapshotDelete(obj interface{}) {
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	volumeName, _, _ := unstructured.NestedString(unstructuredObj.Object, "spec", "volume")
+	checkLonghornVolumeSnapshots(unstructuredObj.GetNamespace(), volumeName)
+}
+
+// longhornSnapshotVolumeIndexFunc indexes snapshots by volume, which they keep in spec.volume
+func longhornSnapshotVolumeIndexFunc(obj interface{}) ([]string, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil
+	}
+	volumeName, _, _ := unstructured.NestedString(u.Object, "spec", "volume")
+	if volumeName == "" {
+		return nil, nil
+	}
+	return []string{volumeName}, nil
+}
+
+// getLonghornSnapshotSummary counts the snapshots of a volume from the snapshot informer cache.
+// It returns nil when snapshots aren't being monitored.
+func getLonghornSnapshotSummary(volumeName string) *longhornSnapshotSummary {
+	snapshotInformer, ok := getLonghornInformer("snapshots")
+	if !ok || !snapshotInformer.HasSynced() {
+		return nil
+	}
+
+	summary := &longhornSnapshotSummary{}
+	for _, obj := range listLonghornObjectsForVolume(snapshotInformer, volumeName) {
+		snapshot, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		// Snapshots marked as removed are waiting to be purged and no longer count towards the limit
+		markRemoved, _, _ := unstructured.NestedBool(snapshot.Object, "status", "markRemoved")
+		if markRemoved {
+			continue
+		}
+
+		info := &longhornSnapshotInfo{name: snapshot.GetName()}
+		info.size, _, _ = unstructured.NestedInt64(snapshot.Object, "status", "size")
+		creationTime, _, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime")
+		if t, err := time.Parse(time.RFC3339, creationTime); err == nil {
+			info.creationTime = t
+		} else {
+			info.creationTime = snapshot.GetCreationTimestamp().Time
+		}
+
+		summary.count++
+		summary.totalSize += info.size
+		if summary.oldest == nil || info.creationTime.Before(summary.oldest.creationTime) {
+			summary.oldest = info
+		}
+		if summary.largest == nil || info.size > summary.largest.size {
+			summary.largest = info
+		}
+	}
+
+	return summary
+}
+
+// getLonghornSnapshotCleanupJobs returns the snapshot cleanup RecurringJobs that apply to a volume
+func getLonghornSnapshotCleanupJobs(volume *unstructured.Unstructured) []string {
+	jobInformer, ok := getLonghornInformer("recurringjobs")
+	if !ok {
+		return nil
+	}
+
+	var jobs []string
+	for _, obj := range jobInformer.GetStore().List() {
+		job, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		task, _, _ := unstructured.NestedString(job.Object, "spec", "task")
+		isCleanup := false
+		for _, cleanupTask := range longhornSnapshotCleanupTasks {
+			if task == cleanupTask {
+				isCleanup = true
+				break
+			}
+		}
+		if !isCleanup {
+			continue
+		}
+
+		groups, _, _ := unstructured.NestedStringSlice(job.Object, "spec", "groups")
+		if len(longhornRecurringJobTargets(job.GetName(), groups, []*unstructured.Unstructured{volume})) > 0 {
+			jobs = append(jobs, job.GetName())
+		}
+	}
+
+	sort.Strings(jobs)
+	return jobs
+}
+
+// checkLonghornVolumeSnapshots checks a volume's snapshot count and total size against the thresholds
+func checkLonghornVolumeSnapshots(namespace, volumeName string) {
+	if volumeName == "" {
+		return
+	}
+
+	volumeInformer, ok := getLonghornInformer("volumes")
+	if !ok {
+		return
+	}
+
+	obj, exists, err := volumeInformer.GetStore().GetByKey(namespace + "/" + volumeName)
+	if err != nil || !exists {
+		return
+	}
+	volume, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	summary := getLonghornSnapshotSummary(volumeName)
+	if summary == nil {
+		return
+	}
+
+	sizeStr, _, _ := unstructured.NestedString(volume.Object, "spec", "size")
+	capacity := parseSize(sizeStr)
+
+	key := fmt.Sprintf("%s/%s", namespace, volumeName)
+
+	log.Debug().
+		Str("volume", volumeName).
+		Int("snapshots", summary.count).
+		Int64("totalSize", summary.totalSize).
+		Msg("Processing volume snapshots")
+
+	hasError := false
+	var errorMessage string
+	var alertType string
+
+	thresholds := config.Longhorn.AlertThresholds
+	if thresholds.SnapshotCount > 0 && summary.count > thresholds.SnapshotCount {
+		hasError = true
+		errorMessage = fmt.Sprintf("Volume has %d snapshots, threshold is %d", summary.count, thresholds.SnapshotCount)
+		alertType = "snapshot_count"
+	}
+
+	if thresholds.SnapshotSizePercent > 0 && capacity > 0 {
+		sizePercent := float64(summary.totalSize) / float64(capacity) * 100
+		if sizePercent > thresholds.SnapshotSizePercent {
+			hasError = true
+			errorMessage = fmt.Sprintf("Snapshots use %.1f%% of the volume size", sizePercent)
+			alertType = "snapshot_size"
+		}
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornSnapshotRecovery(key, volumeName, namespace, summary)
+	}
+
+	// Update state and send alerts
+	updateLonghornSnapshotState(key, hasError, errorMessage, namespace, summary.totalSize, capacity)
+
+	if hasError && shouldSendLonghornAlert("snapshot", key) {
+		cleanupJobs := getLonghornSnapshotCleanupJobs(volume)
+		sendLonghornSnapshotAlert(volumeName, namespace, errorMessage, alertType, capacity, summary, cleanupJobs, getLonghornKubernetesStatus(volume))
+		markLonghornAlertSent("snapshot", key)
+	}
+}
+
+// checkAllLonghornVolumeSnapshots re-evaluates the snapshots of every volume
+func checkAllLonghornVolumeSnapshots() {
+	volumeInformer, ok := getLonghornInformer("volumes")
+	if !ok {
+		return
+	}
+
+	for _, obj := range volumeInformer.GetStore().List() {
+		if volume, ok := obj.(*unstructured.Unstructured); ok {
+			checkLonghornVolumeSnapshots(volume.GetNamespace(), volume.GetName())
+		}
+	}
+}
+
+// formatLonghornSnapshot renders a snapshot for an alert field
+func formatLonghornSnapshot(snapshot *longhornSnapshotInfo) string {
+	if snapshot == nil {
+		return "None"
+	}
+	return fmt.Sprintf("%s (%s, %s old)", snapshot.name, formatLonghornBytes(snapshot.size),
+		time.Since(snapshot.creationTime).Truncate(time.Hour).String())
+}
+
+// formatLonghornSnapshotCleanupJobs renders the cleanup jobs of a volume for an alert field
+func formatLonghornSnapshotCleanupJobs(jobs []string) string {
+	if len(jobs) == 0 {
+		return "None configured, add a snapshot-cleanup or snapshot-delete RecurringJob"
+	}
+	return strings.Join(jobs, ", ")
+}
+
+func updateLonghornSnapshotState(key string, hasError bool, errorMessage, namespace string, totalSize, capacity int64) {
+	longhornSnapshotStatesLock.Lock()
+	defer longhornSnapshotStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := longhornSnapshotStates[key]
+
+	newState := longhornUnitState{
+		unitState: unitState{
+			hasError:    hasError,
+			lastSeen:    now,
+			lastMessage: errorMessage,
+		},
+		resourceType: "snapshot",
+		capacity:     capacity,
+		usage:        totalSize,
+		namespace:    namespace,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	longhornSnapshotStates[key] = newState
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	log "github.com/rs/zerolog/log"
@@ -9,7 +11,7 @@ import (
 )
 
 // processLonghornVolumeStatus processes the status of a Longhorn volume
-func processLonghornVolumeStatus(name, namespace, state, robustness string, capacity, actualSize int64) {
+func processLonghornVolumeStatus(name, namespace, state, robustness string, capacity, actualSize int64, replication *longhornReplicationStatus, kubeStatus *longhornKubernetesStatus) {
 	key := fmt.Sprintf("%s/%s", namespace, name)
 
 	log.Debug().
@@ -42,8 +44,15 @@ func processLonghornVolumeStatus(name, namespace, state, robustness string, capa
 			alertType = "faulted"
 		}
 	case "creating", "attaching", "detaching":
-		// Transitional states, generally OK but monitor
+		// Transitional states, generally OK unless the volume is stuck in them
 		log.Debug().Str("volume", name).Str("state", state).Msg("Volume in transitional state")
+		if maxDuration := longhornTransitionalStateMax(state); maxDuration > 0 {
+			if inState := time.Since(getLonghornVolumeStateSince(key, state)); inState > maxDuration {
+				hasError = true
+				errorMessage = fmt.Sprintf("Volume stuck in %s state for %s", state, inState.Truncate(time.Minute))
+				alertType = "stuck_" + state
+			}
+		}
 	default:
 		// Unknown state
 		hasError = true
@@ -51,8 +60,25 @@ func processLonghornVolumeStatus(name, namespace, state, robustness string, capa
 		alertType = "unknown_state"
 	}
 
-	// Check for volume capacity issues
-	if capacity > 0 && actualSize > 0 {
+	// Check replica count and placement
+	if replication != nil {
+		if int64(replication.healthy) < replication.desired {
+			hasError = true
+			errorMessage = fmt.Sprintf("Volume under-replicated: %d/%d healthy replicas", replication.healthy, replication.desired)
+			alertType = "under_replicated"
+		} else if len(replication.colocatedNodes) > 0 {
+			hasError = true
+			errorMessage = fmt.Sprintf("Replicas co-located on node %s", strings.Join(replication.colocatedNodes, ", "))
+			alertType = "replica_colocated_node"
+		} else if len(replication.colocatedZones) > 0 {
+			hasError = true
+			errorMessage = fmt.Sprintf("Replicas co-located in zone %s", strings.Join(replication.colocatedZones, ", "))
+			alertType = "replica_colocated_zone"
+		}
+	}
+
+	// Check for volume capacity issues, unless the PVC checks already alert on the real filesystem usage
+	if capacity > 0 && actualSize > 0 && !hasAlertingPVCUsage(kubeStatus.pvcRef()) {
 		usagePercent := float64(actualSize) / float64(capacity) * 100
 		remaining := capacity - actualSize
 
@@ -67,20 +93,24 @@ func processLonghornVolumeStatus(name, namespace, state, robustness string, capa
 		}
 	}
 
+	// Check for recovery before the state is reset
+	if !hasError {
+		checkLonghornVolumeRecovery(key, name, namespace, kubeStatus)
+	}
+
 	// Update state and send alerts
 	updateLonghornVolumeState(key, hasError, errorMessage, state, robustness, capacity, actualSize, namespace)
 
 	if hasError && shouldSendLonghornAlert("volume", key) {
-		sendLonghornVolumeAlert(name, namespace, state, robustness, capacity, actualSize, errorMessage, alertType)
+		sendLonghornVolumeAlert(name, namespace, state, robustness, capacity, actualSize, errorMessage, alertType, replication, kubeStatus)
 		markLonghornAlertSent("volume", key)
-	} else if !hasError {
-		// Check for recovery
-		checkLonghornVolumeRecovery(key, name, namespace)
+	} else if hasError && replication != nil {
+		checkLonghornRebuildProgress(key, name, namespace, replication, kubeStatus)
 	}
 }
 
 // processLonghornReplicaStatus processes the status of a Longhorn replica
-func processLonghornReplicaStatus(name, namespace, currentState string) {
+func processLonghornReplicaStatus(name, namespace, currentState, volumeName string, kubeStatus *longhornKubernetesStatus) {
 	key := fmt.Sprintf("%s/%s", namespace, name)
 
 	log.Debug().
@@ -111,15 +141,15 @@ func processLonghornReplicaStatus(name, namespace, currentState string) {
 	updateLonghornReplicaState(key, hasError, errorMessage, currentState, namespace)
 
 	if hasError && shouldSendLonghornAlert("replica", key) {
-		sendLonghornReplicaAlert(name, namespace, currentState, errorMessage)
+		sendLonghornReplicaAlert(name, namespace, currentState, errorMessage, volumeName, kubeStatus)
 		markLonghornAlertSent("replica", key)
 	} else if !hasError {
-		checkLonghornReplicaRecovery(key, name, namespace)
+		checkLonghornReplicaRecovery(key, name, namespace, volumeName, kubeStatus)
 	}
 }
 
 // processLonghornEngineStatus processes the status of a Longhorn engine
-func processLonghornEngineStatus(name, namespace, currentState string) {
+func processLonghornEngineStatus(name, namespace, currentState, volumeName string, kubeStatus *longhornKubernetesStatus) {
 	key := fmt.Sprintf("%s/%s", namespace, name)
 
 	log.Debug().
@@ -150,10 +180,10 @@ func processLonghornEngineStatus(name, namespace, currentState string) {
 	updateLonghornEngineState(key, hasError, errorMessage, currentState, namespace)
 
 	if hasError && shouldSendLonghornAlert("engine", key) {
-		sendLonghornEngineAlert(name, namespace, currentState, errorMessage)
+		sendLonghornEngineAlert(name, namespace, currentState, errorMessage, volumeName, kubeStatus)
 		markLonghornAlertSent("engine", key)
 	} else if !hasError {
-		checkLonghornEngineRecovery(key, name, namespace)
+		checkLonghornEngineRecovery(key, name, namespace, volumeName, kubeStatus)
 	}
 }
 
@@ -207,7 +237,7 @@ func processLonghornNodeStatus(name string, conditions []interface{}) {
 }
 
 // processLonghornBackupStatus processes the status of a Longhorn backup
-func processLonghornBackupStatus(name, namespace, state string) {
+func processLonghornBackupStatus(name, namespace, state, backupTarget string) {
 	key := fmt.Sprintf("%s/%s", namespace, name)
 
 	log.Debug().
@@ -238,13 +268,33 @@ func processLonghornBackupStatus(name, namespace, state string) {
 	updateLonghornBackupState(key, hasError, errorMessage, state, namespace)
 
 	if hasError && shouldSendLonghornAlert("backup", key) {
-		sendLonghornBackupAlert(name, namespace, state, errorMessage)
-		markLonghornAlertSent("backup", key)
+		// The backup target alert already covers every backup failing while the target is down.
+		// The failure is marked as suppressed, so it isn't reported once the target is back
+		// and no recovery is sent for a failure that was never reported.
+		if isLonghornBackupTargetDown(backupTarget) {
+			log.Debug().Str("backup", name).Msg("Backup target is unavailable, suppressing backup alert")
+			markLonghornBackupAlertSuppressed(key)
+		} else {
+			sendLonghornBackupAlert(name, namespace, state, errorMessage)
+			markLonghornAlertSent("backup", key)
+		}
 	} else if !hasError && state == "Completed" {
 		checkLonghornBackupRecovery(key, name, namespace)
 	}
 }
 
+// markLonghornBackupAlertSuppressed settles a backup failure without reporting it
+func markLonghornBackupAlertSuppressed(key string) {
+	longhornBackupStatesLock.Lock()
+	defer longhornBackupStatesLock.Unlock()
+
+	if state, exists := longhornBackupStates[key]; exists {
+		state.alertSent = true
+		state.alertSuppressed = true
+		longhornBackupStates[key] = state
+	}
+}
+
 // State update functions
 func updateLonghornVolumeState(key string, hasError bool, errorMessage, state, robustness string, capacity, actualSize int64, namespace string) {
 	longhornVolumeStatesLock.Lock()
@@ -264,6 +314,13 @@ func updateLonghornVolumeState(key string, hasError bool, errorMessage, state, r
 		usage:        actualSize,
 		robustness:   robustness,
 		namespace:    namespace,
+		state:        state,
+		stateSince:   now,
+	}
+
+	// Keep the time the volume entered its current state
+	if exists && prevState.state == state {
+		newState.stateSince = prevState.stateSince
 	}
 
 	if !exists {
@@ -279,6 +336,7 @@ func updateLonghornVolumeState(key string, hasError bool, errorMessage, state, r
 		} else {
 			newState.firstError = prevState.firstError
 			newState.alertSent = prevState.alertSent
+			newState.rebuildProgress = prevState.rebuildProgress
 		}
 	}
 
@@ -423,8 +481,65 @@ func updateLonghornBackupState(key string, hasError bool, errorMessage, state, n
 		} else {
 			newState.firstError = prevState.firstError
 			newState.alertSent = prevState.alertSent
+			newState.alertSuppressed = prevState.alertSuppressed
 		}
 	}
 
 	longhornBackupStates[key] = newState
 }
+
+// longhornTransitionalStateMax returns how long a volume may stay in a transitional state, 0 if unlimited
+func longhornTransitionalStateMax(state string) time.Duration {
+	var minutes int
+	switch state {
+	case "attaching":
+		minutes = config.Longhorn.AlertThresholds.AttachingMaxMinutes
+	case "detaching":
+		minutes = config.Longhorn.AlertThresholds.DetachingMaxMinutes
+	case "creating":
+		minutes = config.Longhorn.AlertThresholds.CreatingMaxMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// getLonghornVolumeStateSince returns when a volume entered the given state, or now if it just did
+func getLonghornVolumeStateSince(key, state string) time.Time {
+	longhornVolumeStatesLock.RLock()
+	defer longhornVolumeStatesLock.RUnlock()
+
+	if prevState, exists := longhornVolumeStates[key]; exists && prevState.state == state {
+		return prevState.stateSince
+	}
+	return time.Now()
+}
+
+// runLonghornVolumeStateChecks periodically re-evaluates volumes in transitional states,
+// since a stuck volume produces no further events to time it out
+func runLonghornVolumeStateChecks(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			volumeInformer, ok := getLonghornInformer("volumes")
+			if !ok {
+				continue
+			}
+
+			for _, obj := range volumeInformer.GetStore().List() {
+				volume, ok := obj.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				state, _, _ := unstructured.NestedString(volume.Object, "status", "state")
+				if longhornTransitionalStateMax(state) > 0 {
+					// Goes through the volume lock with the latest cached object, so it can't race the informer
+					reevaluateLonghornVolume(volume.GetNamespace(), volume.GetName())
+				}
+			}
+		}
+	}
+}
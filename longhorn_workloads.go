@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// longhornKubernetesStatus is the PVC and workload information Longhorn keeps in a volume's status.kubernetesStatus
+type longhornKubernetesStatus struct {
+	pvName       string
+	pvcName      string
+	pvcNamespace string
+	workloads    []string // "Kind/name" of every workload using the volume
+	pods         []string // "name (status)" of every pod using the volume
+}
+
+// pvcRef returns the consuming PVC as namespace/name, or an empty string if the volume isn't bound
+func (k *longhornKubernetesStatus) pvcRef() string {
+	if k == nil || k.pvcName == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", k.pvcNamespace, k.pvcName)
+}
+
+// getLonghornKubernetesStatus reads status.kubernetesStatus from a Longhorn volume.
+// It returns nil when the volume has never been bound to a PVC.
+func getLonghornKubernetesStatus(volume *unstructured.Unstructured) *longhornKubernetesStatus {
+	kubernetesStatus, found, err := unstructured.NestedMap(volume.Object, "status", "kubernetesStatus")
+	if err != nil || !found {
+		return nil
+	}
+
+	k := &longhornKubernetesStatus{}
+	k.pvName, _, _ = unstructured.NestedString(kubernetesStatus, "pvName")
+	k.pvcName, _, _ = unstructured.NestedString(kubernetesStatus, "pvcName")
+	k.pvcNamespace, _, _ = unstructured.NestedString(kubernetesStatus, "namespace")
+
+	workloads := make(map[string]bool)
+	workloadsStatus, _, _ := unstructured.NestedSlice(kubernetesStatus, "workloadsStatus")
+	for _, workloadInterface := range workloadsStatus {
+		workload, ok := workloadInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		workloadName, _, _ := unstructured.NestedString(workload, "workloadName")
+		workloadType, _, _ := unstructured.NestedString(workload, "workloadType")
+		if workloadName != "" {
+			workloads[fmt.Sprintf("%s/%s", workloadType, workloadName)] = true
+		}
+
+		podName, _, _ := unstructured.NestedString(workload, "podName")
+		podStatus, _, _ := unstructured.NestedString(workload, "podStatus")
+		if podName != "" {
+			k.pods = append(k.pods, fmt.Sprintf("%s (%s)", podName, podStatus))
+		}
+	}
+
+	for workload := range workloads {
+		k.workloads = append(k.workloads, workload)
+	}
+	sort.Strings(k.workloads)
+	sort.Strings(k.pods)
+
+	if k.pvName == "" && k.pvcName == "" && len(k.pods) == 0 {
+		return nil
+	}
+
+	return k
+}
+
+// getLonghornVolumeKubernetesStatus looks up the Kubernetes status of a volume by name, for replicas and engines
+func getLonghornVolumeKubernetesStatus(namespace, volumeName string) *longhornKubernetesStatus {
+	if volumeName == "" {
+		return nil
+	}
+
+	volumeInformer, ok := getLonghornInformer("volumes")
+	if !ok {
+		return nil
+	}
+
+	obj, exists, err := volumeInformer.GetStore().GetByKey(namespace + "/" + volumeName)
+	if err != nil || !exists {
+		return nil
+	}
+
+	volume, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+
+	return getLonghornKubernetesStatus(volume)
+}
+
+// longhornKubernetesFields returns alert fields describing the PVC and workloads using a volume
+func longhornKubernetesFields(volumeName string, kubeStatus *longhornKubernetesStatus) []struct {
+	Name   string
+	Value  string
+	Inline bool
+} {
+	var fields []struct {
+		Name   string
+		Value  string
+		Inline bool
+	}
+
+	if volumeName != "" {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Longhorn Volume", Value: volumeName, Inline: true})
+	}
+
+	if kubeStatus == nil {
+		return fields
+	}
+
+	if pvc := kubeStatus.pvcRef(); pvc != "" {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "PVC", Value: pvc, Inline: true})
+	}
+
+	if len(kubeStatus.workloads) > 0 {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Workloads", Value: strings.Join(kubeStatus.workloads, ", "), Inline: false})
+	}
+
+	if len(kubeStatus.pods) > 0 {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Pods", Value: strings.Join(kubeStatus.pods, "\\n"), Inline: false})
+	}
+
+	return fields
+}
+
+// routeLonghornAlert applies the PVC namespace filters and routes to a storage alert.
+// It returns false if the alert should be dropped.
+func routeLonghornAlert(alert *Alert, kubeStatus *longhornKubernetesStatus) bool {
+	// Volumes that aren't bound to a PVC have no namespace to filter or route on
+	if kubeStatus == nil || kubeStatus.pvcName == "" {
+		return true
+	}
+	namespace := kubeStatus.pvcNamespace
+
+	if len(config.Longhorn.Denylist.Namespaces) > 0 {
+		for _, deniedNamespace := range config.Longhorn.Denylist.Namespaces {
+			if namespace == deniedNamespace {
+				log.Debug().Str("namespace", namespace).Msg("PVC namespace is in Longhorn denylist, dropping alert")
+				return false
+			}
+		}
+	}
+
+	if len(config.Longhorn.Allowlist.Namespaces) > 0 {
+		allowed := false
+		for _, allowedNamespace := range config.Longhorn.Allowlist.Namespaces {
+			if namespace == allowedNamespace {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			log.Debug().Str("namespace", namespace).Msg("PVC namespace is not in Longhorn allowlist, dropping alert")
+			return false
+		}
+	}
+
+	// The first route listing the namespace wins
+	for _, route := range config.Longhorn.Routes {
+		for _, routeNamespace := range route.Namespaces {
+			if namespace == routeNamespace {
+				alert.WebhookUrl = route.WebhookUrl
+				return true
+			}
+		}
+	}
+
+	return true
+}
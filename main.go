@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"os/signal"
@@ -47,6 +48,14 @@ func shouldSendAlert(alertType string, key string) bool {
 			state = nodeState.unitState
 			exists = true
 		}
+	case "pvc":
+		pvcStatesLock.RLock()
+		defer pvcStatesLock.RUnlock()
+		state, exists = pvcStates[key]
+	case "forecast":
+		forecastStatesLock.RLock()
+		defer forecastStatesLock.RUnlock()
+		state, exists = forecastStates[key]
 	case "gitops":
 		gitOpsStatesLock.RLock()
 		defer gitOpsStatesLock.RUnlock()
@@ -54,6 +63,27 @@ func shouldSendAlert(alertType string, key string) bool {
 			state = gitOpsState.unitState
 			exists = true
 		}
+	case "gitops_render":
+		gitOpsRenderStatesLock.RLock()
+		defer gitOpsRenderStatesLock.RUnlock()
+		if gitOpsState, ok := gitOpsRenderStates[key]; ok {
+			state = gitOpsState.unitState
+			exists = true
+		}
+	case "gitops_sync":
+		gitOpsSyncStatesLock.RLock()
+		defer gitOpsSyncStatesLock.RUnlock()
+		if gitOpsState, ok := gitOpsSyncStates[key]; ok {
+			state = gitOpsState.unitState
+			exists = true
+		}
+	case "gitops_rejection":
+		gitOpsRejectionStatesLock.RLock()
+		defer gitOpsRejectionStatesLock.RUnlock()
+		if gitOpsState, ok := gitOpsRejectionStates[key]; ok {
+			state = gitOpsState.unitState
+			exists = true
+		}
 	}
 
 	if !exists || !state.hasError || state.alertSent {
@@ -137,15 +167,48 @@ func sendWebhookMessage(alert Alert) {
 		}]
 	}`, alert.Title, alert.Description, color, fieldsJSON, time.Now().Format(time.RFC3339), version)
 
+	webhookUrl := config.WebhookUrl
+	if alert.WebhookUrl != "" {
+		webhookUrl = alert.WebhookUrl
+	}
+
+	// Files are uploaded as multipart form data with the embed in payload_json
+	body := bytes.NewBufferString(jsonPayload)
+	contentType := "application/json"
+	if len(alert.Attachments) > 0 {
+		body = &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("payload_json", jsonPayload); err != nil {
+			log.Error().Err(err).Msg("Failed to write webhook payload")
+			return
+		}
+		for i, attachment := range alert.Attachments {
+			part, err := writer.CreateFormFile(fmt.Sprintf("files[%d]", i), attachment.Filename)
+			if err != nil {
+				log.Error().Err(err).Str("filename", attachment.Filename).Msg("Failed to attach file to webhook message")
+				return
+			}
+			if _, err := part.Write(attachment.Content); err != nil {
+				log.Error().Err(err).Str("filename", attachment.Filename).Msg("Failed to attach file to webhook message")
+				return
+			}
+		}
+		if err := writer.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to write webhook payload")
+			return
+		}
+		contentType = writer.FormDataContentType()
+	}
+
 	// Create HTTP request
-	req, err := http.NewRequest("POST", config.WebhookUrl, bytes.NewBufferString(jsonPayload))
+	req, err := http.NewRequest("POST", webhookUrl, body)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create HTTP request")
 		return
 	}
 
 	// Set headers
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 
 	// Send request
 	client := &http.Client{}
@@ -223,12 +286,19 @@ func loadConfig(isReload bool) {
 		Int("resource_monitoring_denylist_kinds_count", len(config.ResourceMonitoring.Denylist.Kinds)).
 		Bool("node_monitoring_enabled", config.NodeMonitoring.Enabled).
 		Float64("cpu_threshold_percent", config.NodeMonitoring.CPUThresholdPercent).
+		Bool("node_lifecycle_alerts", config.NodeMonitoring.LifecycleAlerts).
+		Int("node_heartbeat_stale_seconds", config.NodeMonitoring.HeartbeatStaleSeconds).
+		Bool("pvc_monitoring_enabled", config.PVCMonitoring.Enabled).
+		Bool("forecasting_enabled", config.Forecasting.Enabled).
 		Bool("longhorn_enabled", config.Longhorn.Enabled).
 		Str("longhorn_namespace", config.Longhorn.Namespace).
 		Bool("gitops_enabled", config.GitOps.Enabled).
 		Bool("gitops_alert_on_mismatch", config.GitOps.AlertOnMismatch).
 		Int("gitops_sync_interval_minutes", config.GitOps.SyncIntervalMinutes).
+		Int("gitops_history_depth", config.GitOps.HistoryDepth).
+		Bool("gitops_detect_extra_resources", config.GitOps.DetectExtraResources).
 		Bool("gitops_auto_fix_enabled", config.GitOps.AutoFix.Enabled).
+		Bool("gitops_webhook_enabled", config.GitOps.Webhook.Enabled).
 		Int("gitops_repositories_count", len(config.GitOps.Repositories)).
 		Msg("Configuration " + strings.ToLower(action) + "ed")
 }
@@ -268,6 +338,23 @@ func main() {
 	// Set node monitoring defaults
 	viper.SetDefault("node_monitoring.enabled", true)
 	viper.SetDefault("node_monitoring.cpu_threshold_percent", 80.0)
+	viper.SetDefault("node_monitoring.lifecycle_alerts", true)
+	viper.SetDefault("node_monitoring.heartbeat_stale_seconds", 60)
+
+	// Set PVC monitoring defaults
+	viper.SetDefault("pvc_monitoring.enabled", false)
+	viper.SetDefault("pvc_monitoring.interval_seconds", 60)
+	viper.SetDefault("pvc_monitoring.usage_percent", 85.0)
+	viper.SetDefault("pvc_monitoring.capacity_critical", 1073741824)
+	viper.SetDefault("pvc_monitoring.inodes_usage_percent", 90.0)
+
+	// Set capacity forecasting defaults
+	viper.SetDefault("forecasting.enabled", false)
+	viper.SetDefault("forecasting.horizon_days", 7)
+	viper.SetDefault("forecasting.history_hours", 72)
+	viper.SetDefault("forecasting.sample_interval_minutes", 15)
+	viper.SetDefault("forecasting.min_samples", 8)
+	viper.SetDefault("forecasting.configmap_name", "sun-capacity-history")
 
 	// Set Longhorn defaults
 	viper.SetDefault("longhorn.enabled", false)
@@ -277,15 +364,40 @@ func main() {
 	viper.SetDefault("longhorn.monitor.engines", true)
 	viper.SetDefault("longhorn.monitor.nodes", true)
 	viper.SetDefault("longhorn.monitor.backups", true)
+	viper.SetDefault("longhorn.monitor.recurring_jobs", true)
+	viper.SetDefault("longhorn.monitor.backup_targets", true)
+	viper.SetDefault("longhorn.monitor.instance_managers", true)
+	viper.SetDefault("longhorn.monitor.share_managers", true)
+	viper.SetDefault("longhorn.monitor.snapshots", true)
+	viper.SetDefault("longhorn.monitor.engine_images", true)
 	viper.SetDefault("longhorn.alert_thresholds.volume_usage_percent", 85.0)
 	viper.SetDefault("longhorn.alert_thresholds.volume_capacity_critical", 1073741824)
 	viper.SetDefault("longhorn.alert_thresholds.replica_failure_count", 1)
+	viper.SetDefault("longhorn.alert_thresholds.disk_available_percent", 10.0)
+	viper.SetDefault("longhorn.alert_thresholds.disk_available_critical", 5368709120)
+	viper.SetDefault("longhorn.alert_thresholds.snapshot_count", 100)
+	viper.SetDefault("longhorn.alert_thresholds.attaching_max_minutes", 10)
+	viper.SetDefault("longhorn.alert_thresholds.detaching_max_minutes", 10)
+	viper.SetDefault("longhorn.alert_thresholds.creating_max_minutes", 10)
+	viper.SetDefault("longhorn.alert_thresholds.engine_image_grace_hours", 24)
+	viper.SetDefault("longhorn.alert_thresholds.snapshot_size_percent", 100.0)
+	viper.SetDefault("longhorn.backup_age.max_age_hours", 0)
+	viper.SetDefault("longhorn.backup_age.recurring_job_grace_minutes", 60)
 
 	// Set GitOps defaults
 	viper.SetDefault("gitops.enabled", false)
 	viper.SetDefault("gitops.alert_on_mismatch", true)
 	viper.SetDefault("gitops.sync_interval_minutes", 5)
+	viper.SetDefault("gitops.sync_failure_alert_minutes", 30)
+	viper.SetDefault("gitops.history_depth", 50)
+	viper.SetDefault("gitops.detect_extra_resources", false)
 	viper.SetDefault("gitops.auto_fix.enabled", false)
+	viper.SetDefault("gitops.auto_fix.max_per_hour", 10)
+	viper.SetDefault("gitops.auto_fix.cooldown_minutes", 30)
+	viper.SetDefault("gitops.webhook.enabled", false)
+	viper.SetDefault("gitops.webhook.port", 8080)
+	viper.SetDefault("gitops.webhook.path", "/webhooks/git")
+	viper.SetDefault("gitops.webhook.forward_to_leader", true)
 
 	// Set default Kustomize options for all repositories
 	viper.SetDefault("gitops.repositories.kustomize.copyEnvExample", false)
@@ -375,9 +487,10 @@ func main() {
 
 	// Set up node informer (cluster-wide)
 	nodeInformer := factory.Core().V1().Nodes().Informer()
-	nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    handleNode,
-		UpdateFunc: func(_, obj interface{}) { handleNode(obj) },
+	nodeInformer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc:    handleNodeAdd,
+		UpdateFunc: handleNodeUpdate,
+		DeleteFunc: handleNodeDelete,
 	})
 
 	// Start informers
@@ -392,6 +505,16 @@ func main() {
 	}
 	log.Info().Msg("Informer caches synced successfully")
 
+	// Setup node heartbeat monitoring from kube-node-lease
+	err = setupNodeHeartbeatMonitoring(ctx, factory.Core().V1().Nodes().Lister())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to setup node heartbeat monitoring")
+		// Don't exit, node conditions are still monitored
+	}
+
+	// Setup PVC filesystem usage monitoring from kubelet stats
+	setupPVCMonitoring(ctx)
+
 	// Setup Longhorn monitoring if enabled
 	if config.Longhorn.Enabled {
 		err = setupLonghornInformers(ctx)
@@ -401,6 +524,9 @@ func main() {
 		}
 	}
 
+	// Setup capacity forecasting, after Longhorn so its volumes and disks can be sampled
+	setupCapacityForecasting(ctx)
+
 	// Setup GitOps monitoring if enabled
 	if config.GitOps.Enabled {
 		err = setupGitOpsMonitoring(ctx)
@@ -455,9 +581,57 @@ func handleNode(obj interface{}) {
 		Str("node", node.Name).
 		Msg("Processing node status")
 
+	unlock := lockNodeStatus(node.Name)
 	hasError, errorMessage := processNodeStatus(node)
 	updateNodeState(node, hasError, errorMessage)
+	unlock()
 
 	// Also check resource usage if node monitoring is enabled
 	processNodeResourceUsage(node.Name)
 }
+
+// handleNodeAdd processes node add events, reporting nodes that join after the initial list
+func handleNodeAdd(obj interface{}, isInInitialList bool) {
+	handleNode(obj)
+
+	if isInInitialList {
+		return
+	}
+	if node, ok := obj.(*corev1.Node); ok {
+		processNodeJoin(node)
+	}
+}
+
+// handleNodeUpdate processes node update events and checks for lifecycle changes
+func handleNodeUpdate(oldObj, obj interface{}) {
+	handleNode(obj)
+
+	oldNode, ok := oldObj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	processNodeLifecycle(oldNode, node)
+}
+
+// handleNodeDelete processes node delete events from the informer
+func handleNodeDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		log.Error().Msg("Received non-node object in node informer delete")
+		return
+	}
+
+	log.Info().
+		Str("node", node.Name).
+		Msg("Node removed from cluster")
+
+	cleanupNodeState(node.Name)
+	processNodeRemoval(node.Name)
+}
@@ -14,8 +14,19 @@ import (
 var (
 	nodeStates     = make(map[string]unitState)
 	nodeStatesLock sync.RWMutex
+
+	// Serializes status evaluation per node, the informer and the heartbeat ticker both evaluate nodes
+	nodeStatusLocks sync.Map // node name -> *sync.Mutex
 )
 
+// lockNodeStatus holds a node's status lock across the alert check-and-mark, it returns the unlock function
+func lockNodeStatus(nodeName string) func() {
+	lock, _ := nodeStatusLocks.LoadOrStore(nodeName, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
 func markNodeAlertSent(nodeKey string) {
 	nodeStatesLock.Lock()
 	defer nodeStatesLock.Unlock()
@@ -147,8 +158,19 @@ func processNodeStatus(node *corev1.Node) (bool, string) {
 		}
 	}
 
+	// A stale lease means the kubelet stopped heartbeating, usually well before Ready flips
+	heartbeatStale, renewTime, heartbeatAge := getNodeHeartbeatStaleness(node.Name)
+	if heartbeatStale {
+		hasError = true
+		errorMessage = fmt.Sprintf("Node heartbeat stale for %s", heartbeatAge.Round(time.Second))
+		if shouldSendAlert("node", nodeKey) {
+			handleNodeHeartbeatAlert(node, renewTime, heartbeatAge)
+			markNodeAlertSent(nodeKey)
+		}
+	}
+
 	// Success path (node healthy)
-	if readyCond != nil && readyCond.Status == corev1.ConditionTrue &&
+	if readyCond != nil && readyCond.Status == corev1.ConditionTrue && !heartbeatStale &&
 		(memoryCond == nil || memoryCond.Status == corev1.ConditionFalse) &&
 		(diskCond == nil || diskCond.Status == corev1.ConditionFalse) {
 		nodeStatesLock.RLock()
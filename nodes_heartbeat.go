@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	coordinationlisters "k8s.io/client-go/listers/coordination/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Namespace holding the kubelet heartbeat Leases
+const nodeLeaseNamespace = "kube-node-lease"
+
+// How long startup waits for the Lease cache, e.g. when sun can't list Leases
+const nodeLeaseSyncTimeout = 30 * time.Second
+
+// How often node heartbeats are re-evaluated, since a stale Lease produces no events on its own
+const nodeHeartbeatCheckInterval = 15 * time.Second
+
+var (
+	nodeLeaseLister coordinationlisters.LeaseLister
+	nodeLister      corelisters.NodeLister
+)
+
+// setupNodeHeartbeatMonitoring watches node Leases and periodically checks them for staleness
+func setupNodeHeartbeatMonitoring(ctx context.Context, nodes corelisters.NodeLister) error {
+	if config.NodeMonitoring.HeartbeatStaleSeconds <= 0 {
+		log.Info().Msg("Node heartbeat monitoring is disabled")
+		return nil
+	}
+
+	log.Info().
+		Str("namespace", nodeLeaseNamespace).
+		Int("heartbeat_stale_seconds", config.NodeMonitoring.HeartbeatStaleSeconds).
+		Msg("Setting up node heartbeat monitoring")
+
+	nodeLister = nodes
+
+	// The informer gets its own context so it can be stopped if the cache never syncs
+	informerCtx, stopInformer := context.WithCancel(ctx)
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(nodeLeaseNamespace))
+	leaseInformer := factory.Coordination().V1().Leases()
+	informer := leaseInformer.Informer()
+
+	go factory.Start(informerCtx.Done())
+
+	// Don't hold up the rest of startup, a missing Lease RBAC rule would otherwise block it forever
+	syncCtx, cancelSync := context.WithTimeout(informerCtx, nodeLeaseSyncTimeout)
+	defer cancelSync()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		stopInformer()
+		return fmt.Errorf("node lease informer cache didn't sync within %s, heartbeat checks are disabled", nodeLeaseSyncTimeout)
+	}
+
+	nodeLeaseLister = leaseInformer.Lister()
+
+	go func() {
+		defer stopInformer()
+		ticker := time.NewTicker(nodeHeartbeatCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkNodeHeartbeats()
+			}
+		}
+	}()
+
+	log.Info().Msg("Node heartbeat monitoring started")
+	return nil
+}
+
+// checkNodeHeartbeats re-evaluates the status of every node so stale heartbeats are noticed without node updates
+func checkNodeHeartbeats() {
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list nodes for heartbeat check")
+		return
+	}
+
+	for _, node := range nodes {
+		unlock := lockNodeStatus(node.Name)
+		hasError, errorMessage := processNodeStatus(node)
+		updateNodeState(node, hasError, errorMessage)
+		unlock()
+	}
+}
+
+// getNodeHeartbeatStaleness reports whether the node's Lease is older than the configured threshold
+func getNodeHeartbeatStaleness(nodeName string) (bool, time.Time, time.Duration) {
+	if nodeLeaseLister == nil || config.NodeMonitoring.HeartbeatStaleSeconds <= 0 {
+		return false, time.Time{}, 0
+	}
+
+	lease, err := nodeLeaseLister.Leases(nodeLeaseNamespace).Get(nodeName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Debug().Err(err).Str("node", nodeName).Msg("Failed to get node lease")
+		}
+		return false, time.Time{}, 0
+	}
+
+	if lease.Spec.RenewTime == nil {
+		return false, time.Time{}, 0
+	}
+
+	renewTime := lease.Spec.RenewTime.Time
+	age := time.Since(renewTime)
+	threshold := time.Duration(config.NodeMonitoring.HeartbeatStaleSeconds) * time.Second
+
+	return age > threshold, renewTime, age
+}
+
+func handleNodeHeartbeatAlert(node *corev1.Node, renewTime time.Time, age time.Duration) {
+	readyStatus := "Unknown"
+	if readyCond := getNodeCondition(node, corev1.NodeReady); readyCond != nil {
+		readyStatus = string(readyCond.Status)
+	}
+
+	alert := Alert{
+		Title:       fmt.Sprintf("Node %s: Heartbeat Stale", node.Name),
+		Description: fmt.Sprintf("Node %s has not renewed its lease for %s", node.Name, age.Round(time.Second)),
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Node", Value: node.Name, Inline: true},
+			{Name: "Last Heartbeat", Value: renewTime.Format(time.RFC3339), Inline: true},
+			{Name: "Threshold", Value: fmt.Sprintf("%ds", config.NodeMonitoring.HeartbeatStaleSeconds), Inline: true},
+			{Name: "Ready", Value: readyStatus, Inline: true},
+		},
+	}
+	sendWebhookMessage(alert)
+	log.Error().
+		Str("node", node.Name).
+		Time("renew_time", renewTime).
+		Dur("age", age).
+		Str("ready", readyStatus).
+		Msg("Node heartbeat alert sent")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Taint applied by the node controller when a node is cordoned, reported as a cordon event instead
+const unschedulableTaintKey = "node.kubernetes.io/unschedulable"
+
+// sendNodeLifecycleAlert sends a one-shot alert for a node lifecycle event
+func sendNodeLifecycleAlert(nodeName, title, description, event string, extraFields ...struct {
+	Name   string
+	Value  string
+	Inline bool
+}) {
+	alert := Alert{
+		Title:       title,
+		Description: description,
+		Fields: []struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			{Name: "Node", Value: nodeName, Inline: true},
+			{Name: "Event", Value: event, Inline: true},
+		},
+	}
+	alert.Fields = append(alert.Fields, extraFields...)
+
+	sendWebhookMessage(alert)
+	log.Warn().
+		Str("node", nodeName).
+		Str("event", event).
+		Msg("Node lifecycle alert sent")
+}
+
+// processNodeJoin sends an alert for a node that joined the cluster after startup
+func processNodeJoin(node *corev1.Node) {
+	if !config.NodeMonitoring.Enabled || !config.NodeMonitoring.LifecycleAlerts {
+		return
+	}
+
+	sendNodeLifecycleAlert(node.Name,
+		fmt.Sprintf("Node %s Joined", node.Name),
+		fmt.Sprintf("Node %s has joined the cluster", node.Name),
+		"Joined",
+		struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Kubelet Version", Value: node.Status.NodeInfo.KubeletVersion, Inline: true},
+		struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Kernel Version", Value: node.Status.NodeInfo.KernelVersion, Inline: true},
+	)
+}
+
+// processNodeRemoval sends an alert for a node that left the cluster
+func processNodeRemoval(nodeName string) {
+	if !config.NodeMonitoring.Enabled || !config.NodeMonitoring.LifecycleAlerts {
+		return
+	}
+
+	sendNodeLifecycleAlert(nodeName,
+		fmt.Sprintf("Node %s Removed", nodeName),
+		fmt.Sprintf("Node %s has been removed from the cluster", nodeName),
+		"Removed",
+	)
+}
+
+// processNodeLifecycle compares two versions of a node and alerts on cordon, taint and version changes
+func processNodeLifecycle(oldNode, node *corev1.Node) {
+	if !config.NodeMonitoring.Enabled || !config.NodeMonitoring.LifecycleAlerts {
+		return
+	}
+
+	// Cordon / uncordon
+	if oldNode.Spec.Unschedulable != node.Spec.Unschedulable {
+		if node.Spec.Unschedulable {
+			sendNodeLifecycleAlert(node.Name,
+				fmt.Sprintf("Node %s Cordoned", node.Name),
+				fmt.Sprintf("Node %s has been marked unschedulable", node.Name),
+				"Cordoned",
+			)
+		} else {
+			sendNodeLifecycleAlert(node.Name,
+				fmt.Sprintf("Node %s Uncordoned", node.Name),
+				fmt.Sprintf("Node %s is schedulable again", node.Name),
+				"Uncordoned",
+			)
+		}
+	}
+
+	// NoSchedule / NoExecute taints
+	oldTaints := schedulingTaints(oldNode)
+	newTaints := schedulingTaints(node)
+
+	for key, taint := range newTaints {
+		if _, exists := oldTaints[key]; exists {
+			continue
+		}
+		sendNodeLifecycleAlert(node.Name,
+			fmt.Sprintf("Node %s Tainted", node.Name),
+			fmt.Sprintf("Node %s has a new %s taint %s", node.Name, taint.Effect, taint.Key),
+			"Taint Added",
+			taintFields(taint)...,
+		)
+	}
+
+	for key, taint := range oldTaints {
+		if _, exists := newTaints[key]; exists {
+			continue
+		}
+		sendNodeLifecycleAlert(node.Name,
+			fmt.Sprintf("Node %s Taint Removed", node.Name),
+			fmt.Sprintf("Taint %s (%s) has been removed from node %s", taint.Key, taint.Effect, node.Name),
+			"Taint Removed",
+			taintFields(taint)...,
+		)
+	}
+
+	// Kubelet and kernel upgrades
+	oldInfo := oldNode.Status.NodeInfo
+	newInfo := node.Status.NodeInfo
+	if oldInfo.KubeletVersion != "" && oldInfo.KubeletVersion != newInfo.KubeletVersion {
+		sendNodeLifecycleAlert(node.Name,
+			fmt.Sprintf("Node %s Kubelet Version Changed", node.Name),
+			fmt.Sprintf("Kubelet on node %s changed from %s to %s", node.Name, oldInfo.KubeletVersion, newInfo.KubeletVersion),
+			"Kubelet Version Changed",
+			versionFields(oldInfo.KubeletVersion, newInfo.KubeletVersion)...,
+		)
+	}
+	if oldInfo.KernelVersion != "" && oldInfo.KernelVersion != newInfo.KernelVersion {
+		sendNodeLifecycleAlert(node.Name,
+			fmt.Sprintf("Node %s Kernel Version Changed", node.Name),
+			fmt.Sprintf("Kernel on node %s changed from %s to %s", node.Name, oldInfo.KernelVersion, newInfo.KernelVersion),
+			"Kernel Version Changed",
+			versionFields(oldInfo.KernelVersion, newInfo.KernelVersion)...,
+		)
+	}
+}
+
+// schedulingTaints returns the NoSchedule and NoExecute taints of a node keyed by key and effect
+func schedulingTaints(node *corev1.Node) map[string]corev1.Taint {
+	taints := make(map[string]corev1.Taint)
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		// Cordoning is already reported on its own
+		if taint.Key == unschedulableTaintKey {
+			continue
+		}
+		taints[taint.Key+":"+string(taint.Effect)] = taint
+	}
+	return taints
+}
+
+func taintFields(taint corev1.Taint) []struct {
+	Name   string
+	Value  string
+	Inline bool
+} {
+	value := taint.Value
+	if value == "" {
+		value = "-"
+	}
+	return []struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{
+		{Name: "Taint", Value: taint.Key, Inline: true},
+		{Name: "Effect", Value: string(taint.Effect), Inline: true},
+		{Name: "Value", Value: value, Inline: true},
+	}
+}
+
+func versionFields(oldVersion, newVersion string) []struct {
+	Name   string
+	Value  string
+	Inline bool
+} {
+	return []struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{
+		{Name: "Previous", Value: oldVersion, Inline: true},
+		{Name: "Current", Value: newVersion, Inline: true},
+	}
+}
+
+// cleanupNodeState removes all tracked state for a node that no longer exists
+func cleanupNodeState(nodeName string) {
+	nodeStatesLock.Lock()
+	delete(nodeStates, nodeName)
+	nodeStatesLock.Unlock()
+
+	nodeResourceStatesLock.Lock()
+	delete(nodeResourceStates, nodeName)
+	nodeResourceStatesLock.Unlock()
+
+	nodeStatusLocks.Delete(nodeName)
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// kubeletStatsSummary is the subset of the kubelet /stats/summary response sun reads
+type kubeletStatsSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		Volume []struct {
+			Name   string `json:"name"`
+			PVCRef *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+			UsedBytes      *uint64 `json:"usedBytes"`
+			CapacityBytes  *uint64 `json:"capacityBytes"`
+			AvailableBytes *uint64 `json:"availableBytes"`
+			Inodes         *uint64 `json:"inodes"`
+			InodesUsed     *uint64 `json:"inodesUsed"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// pvcUsage is the filesystem usage of a PVC as reported by the kubelet of the node mounting it
+type pvcUsage struct {
+	name           string
+	namespace      string
+	node           string
+	pods           []string
+	usedBytes      int64
+	capacityBytes  int64
+	availableBytes int64
+	inodes         int64
+	inodesUsed     int64
+}
+
+var (
+	pvcUsages     = make(map[string]pvcUsage)
+	pvcUsagesLock sync.RWMutex
+
+	pvcStates     = make(map[string]unitState)
+	pvcStatesLock sync.RWMutex
+)
+
+func markPVCAlertSent(pvcKey string) {
+	pvcStatesLock.Lock()
+	defer pvcStatesLock.Unlock()
+
+	if state, exists := pvcStates[pvcKey]; exists {
+		state.alertSent = true
+		pvcStates[pvcKey] = state
+	}
+}
+
+// setupPVCMonitoring periodically collects PVC filesystem usage from every node's kubelet
+func setupPVCMonitoring(ctx context.Context) {
+	if !config.PVCMonitoring.Enabled {
+		log.Info().Msg("PVC monitoring is disabled")
+		return
+	}
+
+	interval := time.Duration(config.PVCMonitoring.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	log.Info().Dur("interval", interval).Msg("Setting up PVC monitoring from kubelet stats")
+
+	go func() {
+		checkPVCUsage(ctx)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkPVCUsage(ctx)
+			}
+		}
+	}()
+}
+
+// getPVCUsage returns the last known filesystem usage of a PVC by namespace/name
+func getPVCUsage(pvcKey string) (pvcUsage, bool) {
+	if pvcKey == "" {
+		return pvcUsage{}, false
+	}
+
+	pvcUsagesLock.RLock()
+	defer pvcUsagesLock.RUnlock()
+
+	usage, exists := pvcUsages[pvcKey]
+	return usage, exists
+}
+
+// hasAlertingPVCUsage reports whether the PVC checks cover a PVC, so other capacity checks can leave it to them
+func hasAlertingPVCUsage(pvcKey string) bool {
+	usage, exists := getPVCUsage(pvcKey)
+	return exists && isPVCUsageAlerted(usage)
+}
+
+// isPVCUsageAlerted reports whether PVC usage alerts are sent for the PVC's namespace
+func isPVCUsageAlerted(usage pvcUsage) bool {
+	return config.Namespace == "" || usage.namespace == config.Namespace
+}
+
+// How long a single kubelet gets to answer a stats request
+const kubeletStatsTimeout = 10 * time.Second
+
+// getKubeletStatsSummary fetches /stats/summary of a node through the API server proxy
+func getKubeletStatsSummary(ctx context.Context, nodeName string) (*kubeletStatsSummary, error) {
+	// A hanging kubelet must not hold up the other nodes
+	ctx, cancel := context.WithTimeout(ctx, kubeletStatsTimeout)
+	defer cancel()
+
+	data, err := client.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats summary from node %s: %v", nodeName, err)
+	}
+
+	var summary kubeletStatsSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse stats summary from node %s: %v", nodeName, err)
+	}
+
+	return &summary, nil
+}
+
+// listNodeNames returns the names of all nodes, preferring the informer cache
+func listNodeNames(ctx context.Context) ([]string, error) {
+	var names []string
+
+	if nodeLister != nil {
+		nodes, err := nodeLister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+		return names, nil
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes.Items {
+		names = append(names, node.Name)
+	}
+	return names, nil
+}
+
+// checkPVCUsage collects PVC usage from all nodes and evaluates it against the thresholds
+func checkPVCUsage(ctx context.Context) {
+	nodeNames, err := listNodeNames(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list nodes for PVC monitoring")
+		return
+	}
+
+	usages := make(map[string]pvcUsage)
+	complete := true
+
+	for _, nodeName := range nodeNames {
+		// Skip nodes that can't answer, their kubelet is covered by node monitoring
+		if nodeLister != nil {
+			if node, err := nodeLister.Get(nodeName); err == nil {
+				if ready := getNodeCondition(node, corev1.NodeReady); ready == nil || ready.Status != corev1.ConditionTrue {
+					complete = false
+					continue
+				}
+			}
+		}
+
+		summary, err := getKubeletStatsSummary(ctx, nodeName)
+		if err != nil {
+			log.Debug().Err(err).Str("node", nodeName).Msg("Failed to collect kubelet stats")
+			complete = false
+			continue
+		}
+
+		for _, pod := range summary.Pods {
+			for _, volume := range pod.Volume {
+				if volume.PVCRef == nil || volume.CapacityBytes == nil || volume.UsedBytes == nil {
+					continue
+				}
+				// RWX volumes show up once per pod mounting them
+				key := fmt.Sprintf("%s/%s", volume.PVCRef.Namespace, volume.PVCRef.Name)
+				usage, exists := usages[key]
+				if !exists {
+					usage = pvcUsage{
+						name:          volume.PVCRef.Name,
+						namespace:     volume.PVCRef.Namespace,
+						node:          nodeName,
+						usedBytes:     int64(*volume.UsedBytes),
+						capacityBytes: int64(*volume.CapacityBytes),
+					}
+					if volume.AvailableBytes != nil {
+						usage.availableBytes = int64(*volume.AvailableBytes)
+					} else {
+						usage.availableBytes = usage.capacityBytes - usage.usedBytes
+					}
+					if volume.Inodes != nil && volume.InodesUsed != nil {
+						usage.inodes = int64(*volume.Inodes)
+						usage.inodesUsed = int64(*volume.InodesUsed)
+					}
+				}
+				usage.pods = append(usage.pods, pod.PodRef.Name)
+				usages[key] = usage
+			}
+		}
+	}
+
+	pvcUsagesLock.Lock()
+	for key, usage := range usages {
+		pvcUsages[key] = usage
+	}
+	// Only forget PVCs when every node answered, otherwise they may just be on an unreachable node
+	if complete {
+		for key := range pvcUsages {
+			if _, exists := usages[key]; !exists {
+				delete(pvcUsages, key)
+			}
+		}
+	}
+	pvcUsagesLock.Unlock()
+
+	// Usage of every PVC is kept for the Longhorn volume checks, only alerts are limited to the namespace
+	for key, usage := range usages {
+		if !isPVCUsageAlerted(usage) {
+			continue
+		}
+		processPVCUsage(key, usage)
+	}
+
+	if complete {
+		pvcStatesLock.Lock()
+		for key := range pvcStates {
+			if _, exists := usages[key]; !exists {
+				delete(pvcStates, key)
+			}
+		}
+		pvcStatesLock.Unlock()
+	}
+}
+
+// processPVCUsage checks a PVC's filesystem and inode usage and sends alerts if necessary
+func processPVCUsage(key string, usage pvcUsage) {
+	log.Debug().
+		Str("pvc", usage.name).
+		Str("namespace", usage.namespace).
+		Int64("usedBytes", usage.usedBytes).
+		Int64("capacityBytes", usage.capacityBytes).
+		Int64("inodesUsed", usage.inodesUsed).
+		Msg("Processing PVC usage")
+
+	hasError := false
+	var errorMessage string
+	var alertType string
+
+	if usage.capacityBytes > 0 {
+		usagePercent := float64(usage.usedBytes) / float64(usage.capacityBytes) * 100
+
+		if usagePercent > config.PVCMonitoring.UsagePercent {
+			hasError = true
+			errorMessage = fmt.Sprintf("Filesystem usage critical: %.1f%% used", usagePercent)
+			alertType = "usage_critical"
+		} else if usage.availableBytes < config.PVCMonitoring.CapacityCritical {
+			hasError = true
+			errorMessage = fmt.Sprintf("Filesystem capacity critical: %d bytes remaining", usage.availableBytes)
+			alertType = "capacity_critical"
+		}
+	}
+
+	// Inodes run out independently of bytes, e.g. with many small files
+	if usage.inodes > 0 && config.PVCMonitoring.InodesUsagePercent > 0 {
+		inodesPercent := float64(usage.inodesUsed) / float64(usage.inodes) * 100
+		if inodesPercent > config.PVCMonitoring.InodesUsagePercent {
+			hasError = true
+			errorMessage = fmt.Sprintf("Inode usage critical: %.1f%% used", inodesPercent)
+			alertType = "inodes_critical"
+		}
+	}
+
+	// Check for recovery before the state is reset
+	if !hasError {
+		pvcStatesLock.RLock()
+		prevState, exists := pvcStates[key]
+		pvcStatesLock.RUnlock()
+
+		if exists && prevState.hasError && prevState.alertSent {
+			alert := Alert{
+				Title:       fmt.Sprintf("PVC Usage Recovery on %s", usage.namespace),
+				Description: fmt.Sprintf("PVC %s in namespace %s is back within thresholds", usage.name, usage.namespace),
+				Fields:      pvcUsageFields(usage),
+			}
+			alert.Fields = append(alert.Fields, struct {
+				Name   string
+				Value  string
+				Inline bool
+			}{Name: "State", Value: "Healthy", Inline: true})
+			if routeLonghornAlert(&alert, pvcKubernetesStatus(usage)) {
+				sendWebhookMessage(alert)
+			}
+			log.Info().
+				Str("pvc", usage.name).
+				Str("namespace", usage.namespace).
+				Msg("PVC usage has recovered")
+		}
+	}
+
+	updatePVCState(key, hasError, errorMessage)
+
+	if hasError && shouldSendAlert("pvc", key) {
+		alert := Alert{
+			Title:       fmt.Sprintf("PVC Usage Alert on %s", usage.namespace),
+			Description: fmt.Sprintf("PVC %s: %s", usage.name, errorMessage),
+			Fields:      pvcUsageFields(usage),
+		}
+		alert.Fields = append(alert.Fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Alert Type", Value: alertType, Inline: true})
+		// Dropped alerts are still marked, so they aren't retried on every check
+		if routeLonghornAlert(&alert, pvcKubernetesStatus(usage)) {
+			sendWebhookMessage(alert)
+		}
+		markPVCAlertSent(key)
+		log.Error().
+			Str("pvc", usage.name).
+			Str("namespace", usage.namespace).
+			Str("alertType", alertType).
+			Msg("PVC usage alert sent")
+	}
+}
+
+// pvcKubernetesStatus describes the PVC for the storage alert namespace filters and routes
+func pvcKubernetesStatus(usage pvcUsage) *longhornKubernetesStatus {
+	return &longhornKubernetesStatus{pvcName: usage.name, pvcNamespace: usage.namespace}
+}
+
+// pvcUsageFields returns alert fields describing a PVC's filesystem usage
+func pvcUsageFields(usage pvcUsage) []struct {
+	Name   string
+	Value  string
+	Inline bool
+} {
+	usagePercent := float64(0)
+	if usage.capacityBytes > 0 {
+		usagePercent = float64(usage.usedBytes) / float64(usage.capacityBytes) * 100
+	}
+
+	fields := []struct {
+		Name   string
+		Value  string
+		Inline bool
+	}{
+		{Name: "PVC", Value: usage.name, Inline: true},
+		{Name: "Namespace", Value: usage.namespace, Inline: true},
+		{Name: "Node", Value: usage.node, Inline: true},
+		{Name: "Capacity", Value: fmt.Sprintf("%.2f GB", float64(usage.capacityBytes)/(1024*1024*1024)), Inline: true},
+		{Name: "Used", Value: fmt.Sprintf("%.2f GB (%.1f%%)", float64(usage.usedBytes)/(1024*1024*1024), usagePercent), Inline: true},
+	}
+
+	if usage.inodes > 0 {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{
+			Name:   "Inodes",
+			Value:  fmt.Sprintf("%d/%d (%.1f%%)", usage.inodesUsed, usage.inodes, float64(usage.inodesUsed)/float64(usage.inodes)*100),
+			Inline: true,
+		})
+	}
+
+	if len(usage.pods) > 0 {
+		fields = append(fields, struct {
+			Name   string
+			Value  string
+			Inline bool
+		}{Name: "Pods", Value: strings.Join(usage.pods, ", "), Inline: false})
+	}
+
+	return fields
+}
+
+func updatePVCState(key string, hasError bool, errorMessage string) {
+	pvcStatesLock.Lock()
+	defer pvcStatesLock.Unlock()
+
+	now := time.Now()
+	prevState, exists := pvcStates[key]
+
+	newState := unitState{
+		hasError:    hasError,
+		lastSeen:    now,
+		lastMessage: errorMessage,
+	}
+
+	if !exists {
+		newState.firstError = now
+		newState.alertSent = false
+	} else {
+		if hasError && !prevState.hasError {
+			newState.firstError = now
+			newState.alertSent = false
+		} else if !hasError {
+			newState.firstError = time.Time{}
+			newState.alertSent = false
+		} else {
+			newState.firstError = prevState.firstError
+			newState.alertSent = prevState.alertSent
+		}
+	}
+
+	pvcStates[key] = newState
+}
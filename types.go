@@ -12,6 +12,7 @@ import (
 const version = "0.1.4"
 
 var isLeader bool
+var leaderIdentity string // Pod name of the current leader, guarded by leaderLock
 var leaderLock sync.RWMutex
 var config Config
 var client *kubernetes.Clientset
@@ -29,6 +30,12 @@ type Config struct {
 	// Node monitoring configuration
 	NodeMonitoring NodeMonitoringConfig `mapstructure:"node_monitoring"`
 
+	// PVC filesystem usage monitoring configuration
+	PVCMonitoring PVCMonitoringConfig `mapstructure:"pvc_monitoring"`
+
+	// Capacity forecasting configuration
+	Forecasting ForecastingConfig `mapstructure:"forecasting"`
+
 	// Longhorn configuration
 	Longhorn LonghornConfig `mapstructure:"longhorn"`
 
@@ -46,8 +53,27 @@ type ResourceMonitoringDenylist struct {
 }
 
 type NodeMonitoringConfig struct {
-	Enabled             bool    `mapstructure:"enabled"`               // Default: true
-	CPUThresholdPercent float64 `mapstructure:"cpu_threshold_percent"` // Default: 80%
+	Enabled               bool    `mapstructure:"enabled"`                 // Default: true
+	CPUThresholdPercent   float64 `mapstructure:"cpu_threshold_percent"`   // Default: 80%
+	LifecycleAlerts       bool    `mapstructure:"lifecycle_alerts"`        // Default: true
+	HeartbeatStaleSeconds int     `mapstructure:"heartbeat_stale_seconds"` // Default: 60, 0 disables
+}
+
+type PVCMonitoringConfig struct {
+	Enabled            bool    `mapstructure:"enabled"`              // Default: false
+	IntervalSeconds    int     `mapstructure:"interval_seconds"`     // Default: 60
+	UsagePercent       float64 `mapstructure:"usage_percent"`        // Default: 85%
+	CapacityCritical   int64   `mapstructure:"capacity_critical"`    // Default: 1GB remaining
+	InodesUsagePercent float64 `mapstructure:"inodes_usage_percent"` // Default: 90%, 0 disables
+}
+
+type ForecastingConfig struct {
+	Enabled               bool   `mapstructure:"enabled"`                 // Default: false
+	HorizonDays           int    `mapstructure:"horizon_days"`            // Default: 7
+	HistoryHours          int    `mapstructure:"history_hours"`           // Default: 72
+	SampleIntervalMinutes int    `mapstructure:"sample_interval_minutes"` // Default: 15
+	MinSamples            int    `mapstructure:"min_samples"`             // Default: 8
+	ConfigMapName         string `mapstructure:"configmap_name"`          // Default: "sun-capacity-history"
 }
 
 type LonghornConfig struct {
@@ -55,35 +81,91 @@ type LonghornConfig struct {
 	Namespace       string             `mapstructure:"namespace"` // Default: "longhorn-system"
 	Monitor         LonghornMonitor    `mapstructure:"monitor"`
 	AlertThresholds LonghornThresholds `mapstructure:"alert_thresholds"`
+	BackupAge       LonghornBackupAge  `mapstructure:"backup_age"`
+	Allowlist       LonghornFilter     `mapstructure:"allowlist"`
+	Denylist        LonghornFilter     `mapstructure:"denylist"`
+	Routes          []LonghornRoute    `mapstructure:"routes"`
+
+	// Desired values of settings.longhorn.io objects, keyed by setting name
+	ExpectedSettings map[string]interface{} `mapstructure:"expected_settings"`
+}
+
+type LonghornFilter struct {
+	Namespaces []string `mapstructure:"namespaces"` // PVC namespaces, default: empty list
+}
+
+type LonghornRoute struct {
+	Namespaces []string `mapstructure:"namespaces"`  // PVC namespaces sent to this webhook
+	WebhookUrl string   `mapstructure:"webhook_url"` // Discord webhook URL for these namespaces
 }
 
 type LonghornMonitor struct {
-	Volumes  bool `mapstructure:"volumes"`
-	Replicas bool `mapstructure:"replicas"`
-	Engines  bool `mapstructure:"engines"`
-	Nodes    bool `mapstructure:"nodes"`
-	Backups  bool `mapstructure:"backups"`
+	Volumes          bool `mapstructure:"volumes"`
+	Replicas         bool `mapstructure:"replicas"`
+	Engines          bool `mapstructure:"engines"`
+	Nodes            bool `mapstructure:"nodes"`
+	Backups          bool `mapstructure:"backups"`
+	RecurringJobs    bool `mapstructure:"recurring_jobs"`
+	BackupTargets    bool `mapstructure:"backup_targets"`
+	InstanceManagers bool `mapstructure:"instance_managers"`
+	ShareManagers    bool `mapstructure:"share_managers"`
+	Snapshots        bool `mapstructure:"snapshots"`
+	EngineImages     bool `mapstructure:"engine_images"`
 }
 
 type LonghornThresholds struct {
 	VolumeUsagePercent     float64 `mapstructure:"volume_usage_percent"`     // Default: 85%
 	VolumeCapacityCritical int64   `mapstructure:"volume_capacity_critical"` // Default: 1GB remaining
 	ReplicaFailureCount    int     `mapstructure:"replica_failure_count"`    // Default: 1
+	DiskAvailablePercent   float64 `mapstructure:"disk_available_percent"`   // Default: 10%
+	DiskAvailableCritical  int64   `mapstructure:"disk_available_critical"`  // Default: 5GB remaining
+	AttachingMaxMinutes    int     `mapstructure:"attaching_max_minutes"`    // Default: 10, 0 disables
+	DetachingMaxMinutes    int     `mapstructure:"detaching_max_minutes"`    // Default: 10, 0 disables
+	CreatingMaxMinutes     int     `mapstructure:"creating_max_minutes"`     // Default: 10, 0 disables
+	EngineImageGraceHours  int     `mapstructure:"engine_image_grace_hours"` // Default: 24, 0 disables
+	SnapshotCount          int     `mapstructure:"snapshot_count"`           // Default: 100 per volume, 0 disables
+	SnapshotSizePercent    float64 `mapstructure:"snapshot_size_percent"`    // Default: 100% of the volume size, 0 disables
+}
+
+type LonghornBackupAge struct {
+	MaxAgeHours              int                     `mapstructure:"max_age_hours"`               // Default: 0 (disabled)
+	RecurringJobGraceMinutes int                     `mapstructure:"recurring_job_grace_minutes"` // Default: 60
+	Rules                    []LonghornBackupAgeRule `mapstructure:"rules"`
+}
+
+type LonghornBackupAgeRule struct {
+	Volumes       []string `mapstructure:"volumes"`        // Volume names
+	LabelSelector string   `mapstructure:"label_selector"` // Label selector on volumes
+	MaxAgeHours   int      `mapstructure:"max_age_hours"`  // 0 disables the check for matching volumes
 }
 
 type GitOpsConfig struct {
-	Enabled             bool               `mapstructure:"enabled"`               // Default: false
-	AlertOnMismatch     bool               `mapstructure:"alert_on_mismatch"`     // Default: true
-	SyncIntervalMinutes int                `mapstructure:"sync_interval_minutes"` // Default: 5 minutes
-	AutoFix             GitOpsAutoFix      `mapstructure:"auto_fix"`
-	Allowlist           GitOpsFilter       `mapstructure:"allowlist"`
-	Denylist            GitOpsFilter       `mapstructure:"denylist"`
-	Repositories        []GitOpsRepository `mapstructure:"repositories"`
+	Enabled                 bool                     `mapstructure:"enabled"`                    // Default: false
+	AlertOnMismatch         bool                     `mapstructure:"alert_on_mismatch"`          // Default: true
+	SyncIntervalMinutes     int                      `mapstructure:"sync_interval_minutes"`      // Default: 5 minutes
+	SyncFailureAlertMinutes int                      `mapstructure:"sync_failure_alert_minutes"` // Default: 30 minutes
+	HistoryDepth            int                      `mapstructure:"history_depth"`              // Default: 50 commits, 0 for full history
+	DetectExtraResources    bool                     `mapstructure:"detect_extra_resources"`     // Default: false
+	AutoFix                 GitOpsAutoFix            `mapstructure:"auto_fix"`
+	Webhook                 GitOpsWebhook            `mapstructure:"webhook"`
+	Allowlist               GitOpsFilter             `mapstructure:"allowlist"`
+	Denylist                GitOpsFilter             `mapstructure:"denylist"`
+	IgnoreDifferences       []GitOpsIgnoreDifference `mapstructure:"ignore_differences"` // Added to the built-in rules for tooling labels and annotations
+	Repositories            []GitOpsRepository       `mapstructure:"repositories"`
 }
 
 type GitOpsAutoFix struct {
-	Enabled bool     `mapstructure:"enabled"` // Default: false
-	Kinds   []string `mapstructure:"kinds"`   // Default: empty list
+	Enabled         bool     `mapstructure:"enabled"`          // Default: false
+	Kinds           []string `mapstructure:"kinds"`            // Default: empty list
+	MaxPerHour      int      `mapstructure:"max_per_hour"`     // Default: 10
+	CooldownMinutes int      `mapstructure:"cooldown_minutes"` // Default: 30
+}
+
+type GitOpsWebhook struct {
+	Enabled         bool   `mapstructure:"enabled"`           // Default: false
+	Port            int    `mapstructure:"port"`              // Default: 8080
+	Path            string `mapstructure:"path"`              // Default: "/webhooks/git"
+	ForwardToLeader bool   `mapstructure:"forward_to_leader"` // Default: true, followers reject pushes when false
 }
 
 type GitOpsFilter struct {
@@ -92,14 +174,51 @@ type GitOpsFilter struct {
 }
 
 type GitOpsRepository struct {
-	Name                string                `mapstructure:"name"`
-	URL                 string                `mapstructure:"url"`
-	Path                string                `mapstructure:"path"`                  // Default: "."
-	Branch              string                `mapstructure:"branch"`                // Default: "main"
-	AlertOnMismatch     bool                  `mapstructure:"alert_on_mismatch"`     // Default: true
-	AutoFix             bool                  `mapstructure:"auto_fix"`              // Default: false
-	SyncIntervalMinutes int                   `mapstructure:"sync_interval_minutes"` // Default: use global setting
-	Kustomize           GitOpsKustomizeConfig `mapstructure:"kustomize"`
+	Name                string                   `mapstructure:"name"`
+	URL                 string                   `mapstructure:"url"`
+	Path                string                   `mapstructure:"path"`                  // Default: "."
+	Branch              string                   `mapstructure:"branch"`                // Default: "main"
+	AlertOnMismatch     bool                     `mapstructure:"alert_on_mismatch"`     // Default: true
+	AutoFix             bool                     `mapstructure:"auto_fix"`              // Default: false
+	SyncIntervalMinutes int                      `mapstructure:"sync_interval_minutes"` // Default: use global setting
+	Kustomize           GitOpsKustomizeConfig    `mapstructure:"kustomize"`
+	IgnoreDifferences   []GitOpsIgnoreDifference `mapstructure:"ignore_differences"` // Added to the global rules
+	Auth                GitOpsAuth               `mapstructure:"auth"`
+	Webhook             GitOpsRepositoryWebhook  `mapstructure:"webhook"`
+}
+
+// GitOpsRepositoryWebhook references the Secret in sun's namespace holding the push webhook secret of a repository
+type GitOpsRepositoryWebhook struct {
+	SecretName string `mapstructure:"secret_name"`
+	SecretKey  string `mapstructure:"secret_key"` // Default: "webhook-secret"
+}
+
+// GitOpsAuth references a Secret in sun's namespace holding the credentials of a private repository.
+// Key names default to the ones shown.
+type GitOpsAuth struct {
+	Type              string `mapstructure:"type"` // "ssh", "basic", "token" or "github_app", empty for public repositories
+	SecretName        string `mapstructure:"secret_name"`
+	SSHPrivateKeyKey  string `mapstructure:"ssh_private_key_key"` // Default: "ssh-privatekey"
+	KnownHostsKey     string `mapstructure:"known_hosts_key"`     // Default: "known_hosts"
+	PassphraseKey     string `mapstructure:"passphrase_key"`      // Default: "passphrase", optional
+	UsernameKey       string `mapstructure:"username_key"`        // Default: "username"
+	PasswordKey       string `mapstructure:"password_key"`        // Default: "password"
+	TokenKey          string `mapstructure:"token_key"`           // Default: "token"
+	AppIDKey          string `mapstructure:"app_id_key"`          // Default: "app-id"
+	InstallationIDKey string `mapstructure:"installation_id_key"` // Default: "installation-id"
+	AppPrivateKeyKey  string `mapstructure:"app_private_key_key"` // Default: "private-key"
+	GitHubAPIURL      string `mapstructure:"github_api_url"`      // Default: "https://api.github.com"
+}
+
+// GitOpsIgnoreDifference excludes fields from the comparison of matching resources.
+// Empty group, kind, name or namespace match anything.
+type GitOpsIgnoreDifference struct {
+	Group               string   `mapstructure:"group"`
+	Kind                string   `mapstructure:"kind"`
+	Name                string   `mapstructure:"name"`
+	Namespace           string   `mapstructure:"namespace"`
+	JSONPointers        []string `mapstructure:"json_pointers"`        // e.g. /spec/replicas
+	JSONPathExpressions []string `mapstructure:"jsonpath_expressions"` // e.g. .webhooks[*].clientConfig.caBundle
 }
 
 type GitOpsKustomizeConfig struct {
@@ -116,6 +235,15 @@ type Alert struct {
 		Inline bool
 	}
 	Logs string // Add logs field
+
+	WebhookUrl string // Overrides the configured webhook URL when set
+
+	Attachments []AlertAttachment // Files uploaded along with the message
+}
+
+type AlertAttachment struct {
+	Filename string
+	Content  []byte
 }
 
 type unitState struct {
@@ -135,6 +263,12 @@ type longhornUnitState struct {
 	robustness   string
 	node         string
 	namespace    string
+
+	rebuildProgress int64 // Last reported rebuild progress step for volumes
+	alertSuppressed bool  // Backups: the failure was covered by the backup target alert instead
+
+	state      string    // Last seen volume state
+	stateSince time.Time // When the volume entered that state
 }
 
 // Node-specific state for resource monitoring
@@ -153,22 +287,28 @@ type gitOpsState struct {
 	resourceKind   string
 	resourceName   string
 	namespace      string
-	mismatchType   string // "missing", "different", "extra"
+	mismatchType   string // "missing", "different", "extra", "removed", "render_failed", "rejected"
 	expectedHash   string
 	actualHash     string
 }
 
 type gitOpsRepositoryState struct {
-	name         string
-	url          string
-	path         string
-	branch       string
-	localPath    string
-	repository   *git.Repository
-	lastSync     time.Time
-	lastCommit   string
-	syncInterval time.Duration
-	mutex        sync.RWMutex
+	name           string
+	url            string
+	path           string
+	branch         string
+	localPath      string
+	repository     *git.Repository
+	lastSync       time.Time
+	lastCommit     string
+	lastCommitInfo gitOpsCommit
+	syncInterval   time.Duration
+	autoFix        bool
+	auth           GitOpsAuth
+	webhook        GitOpsRepositoryWebhook
+	syncRequests   chan struct{} // Pushes waiting for an immediate sync
+	health         gitOpsRepositoryHealth
+	mutex          sync.RWMutex
 }
 
 // Longhorn state maps
@@ -178,12 +318,34 @@ var (
 	longhornEngineStates  = make(map[string]longhornUnitState)
 	longhornNodeStates    = make(map[string]longhornUnitState)
 	longhornBackupStates  = make(map[string]longhornUnitState)
+	longhornDiskStates    = make(map[string]longhornUnitState)
+
+	longhornBackupAgeStates    = make(map[string]longhornUnitState)
+	longhornRecurringJobStates = make(map[string]longhornUnitState)
+	longhornBackupTargetStates = make(map[string]longhornUnitState)
+
+	longhornInstanceManagerStates = make(map[string]longhornUnitState)
+	longhornShareManagerStates    = make(map[string]longhornUnitState)
+	longhornSnapshotStates        = make(map[string]longhornUnitState)
+	longhornSettingStates         = make(map[string]longhornUnitState)
+	longhornEngineImageStates     = make(map[string]longhornUnitState)
 
 	longhornVolumeStatesLock  sync.RWMutex
 	longhornReplicaStatesLock sync.RWMutex
 	longhornEngineStatesLock  sync.RWMutex
 	longhornNodeStatesLock    sync.RWMutex
 	longhornBackupStatesLock  sync.RWMutex
+	longhornDiskStatesLock    sync.RWMutex
+
+	longhornBackupAgeStatesLock    sync.RWMutex
+	longhornRecurringJobStatesLock sync.RWMutex
+	longhornBackupTargetStatesLock sync.RWMutex
+
+	longhornInstanceManagerStatesLock sync.RWMutex
+	longhornShareManagerStatesLock    sync.RWMutex
+	longhornSnapshotStatesLock        sync.RWMutex
+	longhornSettingStatesLock         sync.RWMutex
+	longhornEngineImageStatesLock     sync.RWMutex
 
 	// Node resource monitoring state
 	nodeResourceStates     = make(map[string]nodeResourceState)
@@ -192,4 +354,11 @@ var (
 	// GitOps state maps
 	gitOpsStates     = make(map[string]gitOpsState)
 	gitOpsStatesLock sync.RWMutex
+
+	gitOpsRenderStates        = make(map[string]gitOpsState) // Keyed by repository
+	gitOpsRenderStatesLock    sync.RWMutex
+	gitOpsRejectionStates     = make(map[string]gitOpsState) // Keyed like gitOpsStates
+	gitOpsRejectionStatesLock sync.RWMutex
+	gitOpsSyncStates          = make(map[string]gitOpsState) // Keyed by repository
+	gitOpsSyncStatesLock      sync.RWMutex
 )